@@ -5,17 +5,39 @@ import "flag"
 import "fmt"
 import "os"
 import "path/filepath"
+import "runtime"
+import "strings"
+import "time"
 
 import "github.com/j-bhm/CppGitMining/pkg/util"
 import "github.com/j-bhm/CppGitMining/pkg/sct"
 import "github.com/j-bhm/CppGitMining/pkg/gct"
 import "github.com/j-bhm/CppGitMining/pkg/git"
+import "github.com/j-bhm/CppGitMining/pkg/stc"
+import "github.com/j-bhm/CppGitMining/pkg/hotspot"
+import "github.com/j-bhm/CppGitMining/pkg/evolution"
+import "github.com/j-bhm/CppGitMining/pkg/lang"
+import "github.com/j-bhm/CppGitMining/pkg/filter"
+import "github.com/j-bhm/CppGitMining/pkg/category"
+import "github.com/j-bhm/CppGitMining/pkg/source"
 
 // struct used to export the analysis results
 type result struct{
     Git map[string]interface{}
     Sct map[string]interface{}
     Gct map[string]interface{}
+    Stc map[string]interface{} `json:",omitempty"`
+    Hotspots map[string]interface{} `json:",omitempty"`
+    Releases map[string]interface{} `json:",omitempty"`
+    SctEvolution map[string]interface{} `json:",omitempty"`
+    GctEvolution map[string]interface{} `json:",omitempty"`
+    PathFilters map[string][]string `json:",omitempty"`
+    ForeignCode map[string]interface{} `json:",omitempty"`
+    Categories map[string]interface{} `json:",omitempty"`
+    Source map[string]interface{} `json:",omitempty"`
+    Preprocessor map[string]interface{} `json:",omitempty"`
+    Includes map[string]interface{} `json:",omitempty"`
+    Features map[string]interface{} `json:",omitempty"`
 }
 
 func main(){
@@ -31,6 +53,64 @@ func main(){
     var forceSctFlag = flag.Bool("force-sct", false, "ignore old sct outputs and rerun analysis")
     var forceGctFlag = flag.Bool("force-gct", false, "ignore old gct outputs and rerun analysis")
     var outputFlag = flag.String("o", "./result.json", "file to save output in")
+    var jobsFlag = flag.Int("j", runtime.NumCPU(), "number of parallel workers")
+    var topFlag = flag.Int("top", 10, "length of ranked result lists, 0 for no limit")
+    var langFlag = flag.String("lang", lang.DefaultProfile, "language profile selecting the analysed source files: " + strings.Join(lang.ProfileNames(), ", "))
+    var repoConfigFlag = flag.String("repo-config", "", "json file mapping repository names to per-repository options, e.g. {\"repo\": {\"Language\": \"c\"}}")
+    var includeFlag = flag.String("include", "", "comma separated glob patterns of the analysed paths, e.g. 'src/**,include/'")
+    var excludeFlag = flag.String("exclude", "", "comma separated glob patterns of paths left out of all analyses, e.g. 'third_party/,vendor/,*_test.cpp'")
+    var foreignFlag = flag.String("foreign", "", "detect generated and vendored code:\nreport: list the detected files and directories and report the git, sct and gct metrics without them separately\nexclude: additionally leave them out of all analyses")
+    var generatedMinLinesFlag = flag.Int("generated-min-lines", 5000, "lines added in a single commit without later changes above which files count as generated, 0 to disable")
+    var refsFlag = flag.String("refs", "objects", "comma separated ref sets the history is traversed from:\nobjects: every commit object in storage\nhead: commits reachable from HEAD\nbranches: commits reachable from local branches\nremotes: commits reachable from remote branches\ntags: commits reachable from tags")
+    var sinceFlag = flag.String("since", "", "only analyse commits authored on or after this date (YYYY-MM-DD), the change coupling is then built from raw co-change counts instead of the gct weights")
+    var untilFlag = flag.String("until", "", "only analyse commits authored before this date (YYYY-MM-DD), files are counted at the last commit before it, the change coupling is then built from raw co-change counts instead of the gct weights")
+    var rangeFlag = flag.String("range", "", "only analyse the commits of the range <base>..<tip>, tip defaults to HEAD, the change coupling is then built from raw co-change counts instead of the gct weights")
+    var firstParentFlag = flag.Bool("first-parent", false, "only follow the first parent of merge commits")
+    var blameFlag = flag.Bool("blame", false, "run line-level blame on the source files at head")
+    var blameMaxSizeFlag = flag.Int64("blame-max-size", 1 << 20, "skip blaming files larger than this number of bytes, 0 for no limit")
+    var blameAgeFlag = flag.Int("blame-age", 2, "age in years above which blamed lines count as old")
+    var branchesFlag = flag.Bool("branches", false, "analyse the branch and merge topology")
+    var classifyFlag = flag.Bool("classify", false, "classify commits by message and diff shape")
+    var classifyKeywordsFlag = flag.String("classify-keywords", "", "file with keyword rules '<class>: <regexp>' for the commit classification")
+    var trailersFlag = flag.Bool("trailers", false, "analyse Co-authored-by, Signed-off-by, Reviewed-by and similar commit trailers")
+    var devNetworkFlag = flag.Bool("devnet", false, "analyse the developer collaboration network")
+    var devNetworkWindowFlag = flag.Int("devnet-window", 30, "time window in days in which developers modifying the same file collaborate")
+    var turnoverFlag = flag.Bool("turnover", false, "analyse contributor turnover and retention")
+    var inactivityFlag = flag.Int("inactivity", 365, "days without commit after which a contributor has left")
+    var coreFlag = flag.Float64("core", 0.8, "share of commits made by the core contributors")
+    var entropyFlag = flag.Bool("entropy", false, "calculate the change entropy per period")
+    var entropyPeriodFlag = flag.Int("entropy-period", 30, "length of the change entropy periods in days")
+    var entropyBurstFlag = flag.Int("entropy-burst", 0, "split the history into bursts separated by gaps of this many hours instead of periods")
+    var duplicatesFlag = flag.Bool("duplicates", false, "detect duplicate patches, cherry-picks and reverts")
+    var congruenceFlag = flag.Bool("congruence", false, "compare sct dependencies with developer collaborations (requires sct output)")
+    var hotspotsFlag = flag.Bool("hotspots", false, "rank files by change frequency and coupling degrees (uses sct and gct outputs if available)")
+    var hotspotChurnFlag = flag.Bool("hotspot-churn", false, "rank hotspots by churn instead of change frequency")
+    var hotspotNormFlag = flag.String("hotspot-norm", "max", "normalisation of the hotspot components:\nmax: divide by the maximum\nrank: use the percentile rank\nnone: use the raw values")
+    var hotspotChangeWeightFlag = flag.Float64("hotspot-weight-changes", 1, "weight of the change frequency or churn in the hotspot score")
+    var hotspotSctWeightFlag = flag.Float64("hotspot-weight-sct", 1, "weight of the static coupling degree in the hotspot score")
+    var hotspotGctWeightFlag = flag.Float64("hotspot-weight-gct", 1, "weight of the git coupling degree in the hotspot score")
+    var hotspotComplexityWeightFlag = flag.Float64("hotspot-weight-complexity", 0, "weight of the cyclomatic complexity in the hotspot score, 0 to leave it out")
+    var sourceMetricsFlag = flag.Bool("source-metrics", false, "calculate size, comment and complexity metrics of the source files at head (uses sct and gct outputs if available)")
+    var preprocessorFlag = flag.Bool("preprocessor", false, "analyse the conditional compilation of the source files at head")
+    var preprocessorCoChangeFlag = flag.Bool("preprocessor-cochange", false, "compare the change coupling of ifdef-heavy files to the other files (uses gct output if available)")
+    var ifdefHeavyShareFlag = flag.Float64("ifdef-heavy-share", 0.25, "minimum share of code under conditional compilation for files to count as ifdef-heavy")
+    var includesFlag = flag.Bool("includes", false, "analyse the include graph of the source files at head (uses the include paths of compile_commands.json if available)")
+    var featuresFlag = flag.Bool("features", false, "profile the use of cpp language features at head and infer the standard from compile_commands.json")
+    var categoriesFlag = flag.Bool("categories", false, "split the metrics into production, test, example and benchmark code (uses sct and gct outputs if available)")
+    var szzFlag = flag.Bool("szz", false, "identify bug-inducing commits of the corrective commits with szz")
+    var releasesFlag = flag.Bool("releases", false, "analyse the release tags")
+    var releaseMetricsFlag = flag.String("release-metrics", "", "comma separated metrics computed at every release tag, implies -releases:\ngit: git metrics of the history up to the release\nsct: static coupling metrics (checks out and builds the release)\ngct: git coupling metrics (checks out the release)")
+    var sctEvolutionFlag = flag.String("sct-evolution", "", "run the sct on sampled historical revisions:\ntags: every n-th release tag\nyearly: last mainline commit of every year")
+    var gctEvolutionFlag = flag.Bool("gct-evolution", false, "analyse the change coupling in sliding windows of the history")
+    var gctWindowDaysFlag = flag.Int("gct-window-days", 365, "length of the change coupling windows in days")
+    var gctWindowCommitsFlag = flag.Int("gct-window-commits", 0, "length of the change coupling windows in commits, overrides -gct-window-days")
+    var gctWindowStepFlag = flag.Int("gct-window-step", 0, "days or commits between the starts of the change coupling windows, 0 for non-overlapping windows")
+    var gctStrongFlag = flag.Int("gct-strong", 2, "minimum co-changes in a window for a file pair to count as strongly coupled")
+    var ignoreRevsFlag = flag.String("ignore-revs-file", "", "file listing commits left out of churn and change coupling metrics")
+    var blameIgnoreRevsFlag = flag.Bool("use-blame-ignore-revs", false, "leave the commits in .git-blame-ignore-revs out of churn and change coupling metrics")
+    var massChangeFilesFlag = flag.Int("mass-change-files", 0, "leave commits changing at least this many files out of churn and change coupling metrics, 0 to disable")
+    var massChangePercentileFlag = flag.Float64("mass-change-percentile", 0, "leave commits changing more files than this percentile of all commits out of churn and change coupling metrics, 0 to disable")
+    var sctEvolutionStepFlag = flag.Int("sct-evolution-step", 1, "number of release tags between revisions sampled with -sct-evolution tags")
  
     // parse flags
     flag.Parse()
@@ -51,6 +131,7 @@ func main(){
     }
     
     // set options
+    var err error
     var opts util.Options
     opts.Verbosity = *verbFlag
     opts.Sct = *sctFlag
@@ -59,6 +140,131 @@ func main(){
     opts.ForceGit = *forceGitFlag
     opts.ForceSct = *forceSctFlag
     opts.ForceGct = *forceGctFlag
+    opts.Jobs = *jobsFlag
+    opts.TopN = *topFlag
+    opts.Language = *langFlag
+    if *includeFlag != ""{
+        opts.Include = strings.Split(*includeFlag, ",")
+    }
+    
+    if *excludeFlag != ""{
+        opts.Exclude = strings.Split(*excludeFlag, ",")
+    }
+    
+    opts.GeneratedMinLines = *generatedMinLinesFlag
+    if *foreignFlag != "" && *foreignFlag != "report" && *foreignFlag != "exclude"{
+        fmt.Println("invalid value for -foreign: " + *foreignFlag)
+        return
+    }
+    opts.Refs = strings.Split(*refsFlag, ",")
+    err = git.CheckRefs(opts.Refs)
+    
+    if err != nil{
+        fmt.Println("invalid value for -refs: " + err.Error())
+        return
+    }
+    opts.FirstParent = *firstParentFlag
+    if *sinceFlag != ""{
+        opts.Since, err = time.Parse("2006-01-02", *sinceFlag)
+        
+        if err != nil{
+            fmt.Println("parsing -since: " + err.Error())
+            return
+        }
+    }
+    
+    if *untilFlag != ""{
+        opts.Until, err = time.Parse("2006-01-02", *untilFlag)
+        
+        if err != nil{
+            fmt.Println("parsing -until: " + err.Error())
+            return
+        }
+        
+        // exclude the day of the cut-off date
+        opts.Until = opts.Until.Add(-time.Nanosecond)
+    }
+    
+    if *rangeFlag != ""{
+        base, tip, ok := strings.Cut(*rangeFlag, "..")
+        if !ok || base == ""{
+            fmt.Println("invalid range: " + *rangeFlag + ", expected <base>..<tip>")
+            return
+        }
+        
+        opts.RangeBase = base
+        opts.Rev = tip
+    }
+    opts.Blame = *blameFlag
+    opts.BlameMaxSize = *blameMaxSizeFlag
+    opts.BlameAgeYears = *blameAgeFlag
+    opts.Branches = *branchesFlag
+    opts.Classify = *classifyFlag
+    opts.ClassifyKeywords = *classifyKeywordsFlag
+    opts.Szz = *szzFlag
+    opts.Trailers = *trailersFlag
+    opts.DevNetwork = *devNetworkFlag
+    opts.DevNetworkWindow = *devNetworkWindowFlag
+    opts.Turnover = *turnoverFlag
+    opts.InactivityDays = *inactivityFlag
+    opts.CoreThreshold = *coreFlag
+    opts.Entropy = *entropyFlag
+    opts.EntropyPeriodDays = *entropyPeriodFlag
+    opts.EntropyBurstHours = *entropyBurstFlag
+    opts.Duplicates = *duplicatesFlag
+    opts.HotspotChurn = *hotspotChurnFlag
+    opts.HotspotNorm = *hotspotNormFlag
+    opts.HotspotChangeWeight = *hotspotChangeWeightFlag
+    opts.HotspotSctWeight = *hotspotSctWeightFlag
+    opts.HotspotGctWeight = *hotspotGctWeightFlag
+    opts.HotspotComplexityWeight = *hotspotComplexityWeightFlag
+    opts.PreprocessorCoChange = *preprocessorCoChangeFlag
+    opts.IfdefHeavyShare = *ifdefHeavyShareFlag
+    if *releaseMetricsFlag != ""{
+        opts.ReleaseMetrics = strings.Split(*releaseMetricsFlag, ",")
+    }
+    opts.EvolutionSampling = *sctEvolutionFlag
+    opts.EvolutionStep = *sctEvolutionStepFlag
+    opts.CouplingWindowDays = *gctWindowDaysFlag
+    opts.CouplingWindowCommits = *gctWindowCommitsFlag
+    opts.CouplingWindowStep = *gctWindowStepFlag
+    opts.CouplingMinStrength = *gctStrongFlag
+    opts.IgnoreRevsFile = *ignoreRevsFlag
+    opts.UseBlameIgnoreRevs = *blameIgnoreRevsFlag
+    opts.MassChangeFiles = *massChangeFilesFlag
+    opts.MassChangePercentile = *massChangePercentileFlag
+    
+    // load per-repository options
+    var configs map[string]util.RepoConfig
+    if *repoConfigFlag != ""{
+        configs, err = util.LoadRepoConfigs(*repoConfigFlag)
+        
+        if err != nil{
+            fmt.Println("parsing -repo-config: " + err.Error())
+            return
+        }
+    }
+    
+    // check language profiles
+    _, err = lang.Lookup(opts.Language)
+    
+    if err != nil{
+        fmt.Println(err.Error())
+        return
+    }
+    
+    for name, config := range(configs){
+        if config.Language == ""{
+            continue
+        }
+        
+        _, err = lang.Lookup(config.Language)
+        
+        if err != nil{
+            fmt.Println(name + ": " + err.Error())
+            return
+        }
+    }
     
     // parse input file
     inputPath := flag.Arg(0)
@@ -71,7 +277,7 @@ func main(){
     
     // clone and build repositories
     util.PrintStatus("loading repositories:", opts)
-    repos := git.LoadRepos(urls, commands, opts)
+    repos, repoCommands := git.LoadRepos(urls, commands, opts)
     
     // run analyses
     util.PrintStatus("analysing repositories:", opts)
@@ -81,13 +287,35 @@ func main(){
         var res result
         util.PrintStatus(fmt.Sprintf("[%d/%d] %s", i + 1, len(repos), repo), opts)
         
+        // apply per-repository options
+        repoOpts := util.RepoOptions(opts, configs, filepath.Base(repo))
+        
+        // detect generated and vendored code
+        var foreignPatterns []string
+        if *foreignFlag != ""{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] detecting generated and vendored code", i + 1, len(repos)), repoOpts)
+            foreignResult, patterns, err := git.AnalyseForeignCode(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.ForeignCode = foreignResult
+            foreignPatterns = patterns
+            if *foreignFlag == "exclude"{
+                repoOpts.Exclude = append(repoOpts.Exclude, patterns...)
+            }
+        }
+        res.PathFilters = filter.Description(repoOpts)
+        
         // run git analysis
         if !*skipGitFlag{
-            util.PrintStatus(fmt.Sprintf("[%d/%d] running git analysis", i + 1, len(repos)), opts)
-            gitResult, err := git.RunGitAnalysis(repo, opts)
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running git analysis", i + 1, len(repos)), repoOpts)
+            gitResult, err := git.RunGitAnalysis(repo, repoOpts)
             
             if err != nil{
-                util.PrintError(err.Error(), opts)
+                util.PrintError(err.Error(), repoOpts)
                 continue
             }
             
@@ -96,11 +324,11 @@ func main(){
         
         // run sct analysis
         if !*skipSctFlag{
-            util.PrintStatus(fmt.Sprintf("[%d/%d] running static coupling analysis", i + 1, len(repos)), opts)
-            sctResult, err := sct.RunSctAnalysis(repo, opts)
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running static coupling analysis", i + 1, len(repos)), repoOpts)
+            sctResult, err := sct.RunSctAnalysis(repo, repoOpts)
             
             if err != nil{
-                util.PrintError(err.Error(), opts)
+                util.PrintError(err.Error(), repoOpts)
                 continue
             }
             
@@ -109,17 +337,186 @@ func main(){
         
         // run gct analysis
         if !*skipGctFlag{
-            util.PrintStatus(fmt.Sprintf("[%d/%d] running git coupling analysis", i + 1, len(repos)), opts)
-            gctResult, err := gct.RunGctAnalysis(repo, opts)
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running git coupling analysis", i + 1, len(repos)), repoOpts)
+            gctResult, err := gct.RunGctAnalysis(repo, repoOpts)
             
             if err != nil{
-                util.PrintError(err.Error(), opts)
+                util.PrintError(err.Error(), repoOpts)
                 continue
             }
             
             res.Gct = gctResult
         }
         
+        // report the metrics without the detected code separately
+        if *foreignFlag == "report" && len(foreignPatterns) > 0{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running analyses without generated and vendored code", i + 1, len(repos)), repoOpts)
+            ownOpts := repoOpts
+            ownOpts.Exclude = append(append([]string{}, repoOpts.Exclude...), foreignPatterns...)
+            if !*skipGitFlag{
+                gitResult, err := git.RunGitAnalysis(repo, ownOpts)
+                
+                if err != nil{
+                    util.PrintError(err.Error(), repoOpts)
+                    continue
+                }
+                
+                res.ForeignCode["OwnCodeGit"] = gitResult
+            }
+            
+            if !*skipSctFlag{
+                sctResult, err := sct.RunSctAnalysis(repo, ownOpts)
+                
+                if err != nil{
+                    util.PrintError(err.Error(), repoOpts)
+                    continue
+                }
+                
+                res.ForeignCode["OwnCodeSct"] = sctResult
+            }
+            
+            if !*skipGctFlag{
+                gctResult, err := gct.RunGctAnalysis(repo, ownOpts)
+                
+                if err != nil{
+                    util.PrintError(err.Error(), repoOpts)
+                    continue
+                }
+                
+                res.ForeignCode["OwnCodeGct"] = gctResult
+            }
+        }
+        
+        // run socio-technical congruence analysis
+        if *congruenceFlag{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running socio-technical congruence analysis", i + 1, len(repos)), repoOpts)
+            stcResult, err := stc.RunStcAnalysis(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.Stc = stcResult
+        }
+        
+        // run source metrics analysis
+        if *sourceMetricsFlag{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running source metrics analysis", i + 1, len(repos)), repoOpts)
+            sourceResult, err := source.RunSourceAnalysis(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.Source = sourceResult
+        }
+        
+        // run preprocessor variability analysis
+        if *preprocessorFlag || *preprocessorCoChangeFlag{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running preprocessor analysis", i + 1, len(repos)), repoOpts)
+            preprocessorResult, err := source.RunPreprocessorAnalysis(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.Preprocessor = preprocessorResult
+        }
+        
+        // run include graph analysis
+        if *includesFlag{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running include graph analysis", i + 1, len(repos)), repoOpts)
+            includeResult, err := source.RunIncludeAnalysis(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.Includes = includeResult
+        }
+        
+        // run language feature analysis
+        if *featuresFlag{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running language feature analysis", i + 1, len(repos)), repoOpts)
+            featureResult, err := source.RunFeatureAnalysis(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.Features = featureResult
+        }
+        
+        // run hotspot analysis
+        if *hotspotsFlag{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running hotspot analysis", i + 1, len(repos)), repoOpts)
+            hotspotResult, err := hotspot.RunHotspotAnalysis(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.Hotspots = hotspotResult
+        }
+        
+        // run file category analysis
+        if *categoriesFlag{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running file category analysis", i + 1, len(repos)), repoOpts)
+            categoryResult, err := category.RunCategoryAnalysis(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.Categories = categoryResult
+        }
+        
+        // run release analysis
+        if *releasesFlag || *releaseMetricsFlag != ""{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running release analysis", i + 1, len(repos)), repoOpts)
+            releaseResult, err := evolution.RunReleaseAnalysis(repo, repoCommands[i], repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.Releases = releaseResult
+        }
+        
+        // run sct analysis on historical revisions
+        if *sctEvolutionFlag != ""{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running static coupling evolution analysis", i + 1, len(repos)), repoOpts)
+            evolutionResult, err := evolution.RunSctEvolution(repo, repoCommands[i], repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.SctEvolution = evolutionResult
+        }
+        
+        // run change coupling analysis on history windows
+        if *gctEvolutionFlag{
+            util.PrintStatus(fmt.Sprintf("[%d/%d] running change coupling evolution analysis", i + 1, len(repos)), repoOpts)
+            evolutionResult, err := evolution.RunGctEvolution(repo, repoOpts)
+            
+            if err != nil{
+                util.PrintError(err.Error(), repoOpts)
+                continue
+            }
+            
+            res.GctEvolution = evolutionResult
+        }
+        
         // add result to output
         gitName := filepath.Base(repo)
         output[gitName] = res
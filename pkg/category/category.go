@@ -0,0 +1,393 @@
+package category
+
+import (
+    "io"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/gct"
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/sct"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// categories of source files
+const (
+    Production = "production"
+    Test = "test"
+    Example = "example"
+    Benchmark = "benchmark"
+)
+
+// all categories in output order
+var Categories = []string{Production, Test, Example, Benchmark}
+
+// number of bytes at the start of files searched for includes
+const includeHeaderSize = 8192
+
+// directory names of the non-production categories
+var categoryDirs = map[string][]string{
+    Test: {"test", "tests", "testing", "unittest", "unittests", "unit_tests", "testsuite"},
+    Example: {"example", "examples", "sample", "samples", "demo", "demos", "tutorial", "tutorials"},
+    Benchmark: {"bench", "benchs", "benchmark", "benchmarks", "perf"},
+}
+
+// matches affixes of test and benchmark file names
+var testNameRegexp = regexp.MustCompile(`(^[Tt]ests?_|_[Tt]ests?$|_unittest$|\.test$|[a-z0-9]Test$|^test[A-Z])`)
+var benchmarkNameRegexp = regexp.MustCompile(`(?i)(^bench(mark)?_|_bench(mark)?$)`)
+
+// matches includes of test and benchmark frameworks
+var testIncludeRegexp = regexp.MustCompile(`#\s*include\s*[<"](gtest/|gmock/|catch2/|catch\.hpp|CUnit/|boost/test/|doctest|check\.h|cppunit/|criterion/)`)
+var benchmarkIncludeRegexp = regexp.MustCompile(`#\s*include\s*[<"](benchmark/benchmark\.h|nonius/|celero/)`)
+
+// struct holding the metrics of a file category
+type CategoryMetrics struct{
+    FileCount int
+    CommitCount int       // commits changing files of the category
+    Churn int             // added and deleted lines
+    ContributorCount int  // authors of these commits
+    Sct map[string]interface{} `json:",omitempty"`
+    Gct map[string]interface{} `json:",omitempty"`
+}
+
+// Run the file category analysis on the repository in path.
+// Uses the outputs of previous sct and gct runs if available.
+// Mass-change commits selected by opts are left out of the history.
+// Outputs a map with the following fields:
+//   Categories                  map[string]CategoryMetrics
+//   TestProductionCoChangeRatio float64
+//   CoveredFileCount            int
+//   TestCoChangeRatio           float64
+func RunCategoryAnalysis(path string, opts util.Options) (map[string]interface{}, error){
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // classify files
+    util.PrintDebug("classifying files", opts)
+    categories, err := ClassifyFiles(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, err := git.LoadHistory(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, _, err = git.RemoveMassChanges(path, history, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // load graphs of previous runs
+    sctJson, err := sct.LoadSctOutput(path, opts)
+    if err == nil{
+        sctJson, err = sct.FilterSctJson(sctJson, path, opts)
+    }
+
+    if err != nil{
+        util.PrintDebug("no sct output for categories: " + err.Error(), opts)
+        sctJson = nil
+    }
+
+    gctJson, err := gct.LoadFilteredGctJson(path, opts)
+
+    if err != nil{
+        util.PrintDebug("no gct output for categories: " + err.Error(), opts)
+        gctJson = nil
+    }
+
+    result := AnalyseCategories(categories, history, opts)
+
+    // add coupling metrics per category
+    metrics := result["Categories"].(map[string]*CategoryMetrics)
+    if sctJson != nil{
+        paths := sct.SctNodePaths(sctJson, path)
+        for _, category := range(Categories){
+            sub := subSctJson(sctJson, func(id string) bool{
+                return categories[paths[id]] == category
+            })
+
+            if len(sub.Nodes) > 0{
+                metrics[category].Sct = sct.AnalyseSctOutput(sub)
+            }
+        }
+    }
+
+    if gctJson != nil{
+        for _, category := range(Categories){
+            sub := subGctJson(gctJson, func(id string) bool{
+                return categories[filepath.ToSlash(filepath.Clean(id))] == category
+            })
+
+            if len(sub.Nodes) > 0{
+                metrics[category].Gct = gct.AnalyseGctOutput(sub)
+            }
+        }
+    }
+
+    return result, nil
+}
+
+// Classify the source files at the analysed head of repo into
+// production, test, example and benchmark code, using the
+// directory and file names and the included test and
+// benchmark frameworks. Returns a map from paths to categories.
+func ClassifyFiles(repo *gogit.Repository, opts util.Options) (map[string]string, error){
+    headCommit, err := git.ResolveHead(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    fileIter, err := headCommit.Files()
+
+    if err != nil{
+        return nil, err
+    }
+
+    categories := make(map[string]string)
+    err = fileIter.ForEach(func(file *object.File) error{
+        if !git.IsSourceFile(file.Name, opts){
+            return nil
+        }
+
+        category, err := classifyFile(file)
+
+        if err != nil{
+            return err
+        }
+
+        categories[file.Name] = category
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    return categories, nil
+}
+
+// Calculate the history metrics of the file categories and
+// the co-changes of test and production files in history.
+// categories maps paths to their category.
+// Returns a map with the fields of RunCategoryAnalysis,
+// where Categories maps to *CategoryMetrics without
+// coupling metrics. A production file is covered if a test
+// has the same name without the test affixes.
+func AnalyseCategories(categories map[string]string, history []*git.HistoryCommit, opts util.Options) map[string]interface{}{
+    util.PrintDebug("analysing file categories", opts)
+    metrics := make(map[string]*CategoryMetrics)
+    authors := make(map[string]map[string]bool)
+    for _, category := range(Categories){
+        metrics[category] = new(CategoryMetrics)
+        authors[category] = make(map[string]bool)
+    }
+
+    for _, category := range(categories){
+        metrics[category].FileCount += 1
+    }
+
+    // match tests to the production files they cover
+    tests := make(map[string][]string)
+    for file, category := range(categories){
+        if category == Test{
+            name := coveredName(file)
+            tests[name] = append(tests[name], file)
+        }
+    }
+
+    covered := make(map[string][]string)
+    for file, category := range(categories){
+        if category == Production{
+            if files, ok := tests[baseName(file)]; ok{
+                covered[file] = files
+            }
+        }
+    }
+
+    // collect changes per category
+    testCommits := 0
+    testProductionCommits := 0
+    coveredChanges := 0
+    coveredCoChanges := 0
+    for _, commit := range(history){
+        changed := make(map[string]bool)
+        touched := make(map[string]bool)
+        for _, change := range(commit.Changes){
+            category, ok := categories[change.Path]
+            if !ok{
+                continue
+            }
+
+            changed[change.Path] = true
+            if !touched[category]{
+                touched[category] = true
+                metrics[category].CommitCount += 1
+                authors[category][commit.Author] = true
+            }
+
+            metrics[category].Churn += change.Additions + change.Deletions
+        }
+
+        if touched[Test]{
+            testCommits += 1
+            if touched[Production]{
+                testProductionCommits += 1
+            }
+        }
+
+        // test if covered files change together with their tests
+        for file := range(changed){
+            files, ok := covered[file]
+            if !ok{
+                continue
+            }
+
+            coveredChanges += 1
+            for _, test := range(files){
+                if changed[test]{
+                    coveredCoChanges += 1
+                    break
+                }
+            }
+        }
+    }
+
+    for _, category := range(Categories){
+        metrics[category].ContributorCount = len(authors[category])
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["Categories"] = metrics
+    result["TestProductionCoChangeRatio"] = util.Ratio(testProductionCommits, testCommits)
+    result["CoveredFileCount"] = len(covered)
+    result["TestCoChangeRatio"] = util.Ratio(coveredCoChanges, coveredChanges)
+
+    return result
+}
+
+// Classify a single source file.
+func classifyFile(file *object.File) (string, error){
+    // test directory names, the innermost directory decides
+    dirs := strings.Split(path.Dir(file.Name), "/")
+    for i := len(dirs) - 1; i >= 0; i--{
+        for _, category := range([]string{Test, Benchmark, Example}){
+            if util.ContainsString(categoryDirs[category], strings.ToLower(dirs[i])){
+                return category, nil
+            }
+        }
+    }
+
+    // test file names
+    name := baseName(file.Name)
+    if benchmarkNameRegexp.MatchString(name){
+        return Benchmark, nil
+    }
+
+    if testNameRegexp.MatchString(name){
+        return Test, nil
+    }
+
+    // test included frameworks
+    reader, err := file.Reader()
+
+    if err != nil{
+        return "", err
+    }
+    defer reader.Close()
+
+    header := make([]byte, includeHeaderSize)
+    n, err := io.ReadFull(reader, header)
+
+    if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF{
+        return "", err
+    }
+
+    if benchmarkIncludeRegexp.Match(header[:n]){
+        return Benchmark, nil
+    }
+
+    if testIncludeRegexp.Match(header[:n]){
+        return Test, nil
+    }
+
+    return Production, nil
+}
+
+// Return the base name of a file without extension.
+func baseName(file string) string{
+    name := path.Base(file)
+    return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// Return the base name of the file covered by a test file.
+func coveredName(file string) string{
+    name := baseName(file)
+    for _, affix := range([]string{"_unittest", "_tests", "_test", ".test", "Test"}){
+        if strings.HasSuffix(name, affix) && len(name) > len(affix){
+            return strings.TrimSuffix(name, affix)
+        }
+    }
+
+    for _, affix := range([]string{"tests_", "test_"}){
+        if strings.HasPrefix(name, affix) && len(name) > len(affix){
+            return strings.TrimPrefix(name, affix)
+        }
+    }
+
+    return name
+}
+
+// Return the sub graph of sctJson with the nodes selected by keep.
+func subSctJson(sctJson *sct.SctJson, keep func(id string) bool) *sct.SctJson{
+    result := new(sct.SctJson)
+    kept := make(map[string]bool)
+    for _, node := range(sctJson.Nodes){
+        if keep(node.Id){
+            kept[node.Id] = true
+            result.Nodes = append(result.Nodes, node)
+        }
+    }
+
+    for _, edge := range(sctJson.Edges){
+        if kept[edge.Start] && kept[edge.End]{
+            result.Edges = append(result.Edges, edge)
+        }
+    }
+
+    return result
+}
+
+// Return the sub graph of gctJson with the nodes selected by keep.
+func subGctJson(gctJson *gct.GctJson, keep func(id string) bool) *gct.GctJson{
+    result := new(gct.GctJson)
+    kept := make(map[string]bool)
+    for _, node := range(gctJson.Nodes){
+        if keep(node.Id){
+            kept[node.Id] = true
+            result.Nodes = append(result.Nodes, node)
+        }
+    }
+
+    for _, edge := range(gctJson.Edges){
+        if kept[edge.Start] && kept[edge.End]{
+            result.Edges = append(result.Edges, edge)
+        }
+    }
+
+    return result
+}
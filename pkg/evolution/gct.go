@@ -0,0 +1,182 @@
+package evolution
+
+import (
+    "errors"
+    "sort"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/gct"
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+)
+
+// struct describing the change coupling of a history window
+type CouplingWindow struct{
+    Start time.Time
+    End time.Time
+    Commits int
+    Gct map[string]interface{} `json:",omitempty"`
+    StrongPairCount int      // pairs with at least opts.CouplingMinStrength co-changes
+    PersistentPairCount int  // strong pairs also strong in the previous window
+    Stability float64        // share of the previous strong pairs persisting
+}
+
+// Run the change coupling analysis on sliding windows of the history
+// of the repository in path. The coupling graphs are built natively
+// from the co-changes of source files in each window,
+// leaving out the mass-change commits selected by opts.
+// Outputs a map with the following fields:
+//   GctWindowCount       int
+//   AvgCouplingStability float64
+//   GctWindows           []CouplingWindow
+func RunGctEvolution(path string, opts util.Options) (map[string]interface{}, error){
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, err := git.LoadHistory(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, _, err = git.RemoveMassChanges(path, history, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    util.PrintDebug("analysing change coupling windows", opts)
+    return AnalyseCouplingWindows(history, opts)
+}
+
+// Analyse the change coupling of history in sliding windows of
+// opts.CouplingWindowCommits commits if set or opts.CouplingWindowDays
+// days otherwise, advanced by opts.CouplingWindowStep commits or days.
+// A step of 0 uses non-overlapping windows.
+// Returns a map with the fields of RunGctEvolution.
+func AnalyseCouplingWindows(history []*git.HistoryCommit, opts util.Options) (map[string]interface{}, error){
+    // collect non-merge commits
+    var commits []*git.HistoryCommit
+    for _, commit := range(history){
+        if len(commit.Parents) <= 1{
+            commits = append(commits, commit)
+        }
+    }
+
+    windows, err := splitWindows(commits, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // analyse windows
+    result := make(map[string]interface{})
+    series := make([]CouplingWindow, 0, len(windows))
+    var previous map[gct.FilePair]bool
+    var stabilities []float64
+    for _, window := range(windows){
+        changes, pairs := gct.CoChanges(window.commits, opts)
+        w := CouplingWindow{Start: window.start, End: window.end, Commits: len(window.commits)}
+        if len(changes) > 0{
+            w.Gct = gct.AnalyseGctOutput(gct.CoChangeJson(changes, pairs, 1))
+        }
+
+        // compare strong pairs with the previous window
+        strong := make(map[gct.FilePair]bool)
+        for pair, count := range(pairs){
+            if count >= opts.CouplingMinStrength{
+                strong[pair] = true
+                if previous[pair]{
+                    w.PersistentPairCount += 1
+                }
+            }
+        }
+
+        w.StrongPairCount = len(strong)
+        if len(previous) > 0{
+            w.Stability = float64(w.PersistentPairCount) / float64(len(previous))
+            stabilities = append(stabilities, w.Stability)
+        }
+
+        previous = strong
+        series = append(series, w)
+    }
+
+    // set result values
+    result["GctWindowCount"] = len(series)
+    result["AvgCouplingStability"] = util.Mean(stabilities)
+    result["GctWindows"] = series
+
+    return result, nil
+}
+
+// struct representing a window of the history
+type historyWindow struct{
+    start time.Time
+    end time.Time
+    commits []*git.HistoryCommit
+}
+
+// Split the commits ordered by date into the windows selected by opts.
+func splitWindows(commits []*git.HistoryCommit, opts util.Options) ([]historyWindow, error){
+    var windows []historyWindow
+    if len(commits) == 0{
+        return windows, nil
+    }
+
+    // windows of a fixed number of commits
+    if opts.CouplingWindowCommits > 0{
+        step := opts.CouplingWindowStep
+        if step <= 0{
+            step = opts.CouplingWindowCommits
+        }
+
+        for start := 0; start < len(commits); start += step{
+            end := start + opts.CouplingWindowCommits
+            if end > len(commits){
+                end = len(commits)
+            }
+
+            windows = append(windows, historyWindow{commits[start].When, commits[end - 1].When, commits[start:end]})
+            if end == len(commits){
+                break
+            }
+        }
+
+        return windows, nil
+    }
+
+    // windows of a fixed time span
+    if opts.CouplingWindowDays <= 0{
+        return nil, errors.New("change coupling window size must be positive")
+    }
+
+    size := time.Duration(opts.CouplingWindowDays) * 24 * time.Hour
+    step := time.Duration(opts.CouplingWindowStep) * 24 * time.Hour
+    if step <= 0{
+        step = size
+    }
+
+    last := commits[len(commits) - 1].When
+    for start := commits[0].When; !start.After(last); start = start.Add(step){
+        end := start.Add(size)
+        from := sort.Search(len(commits), func(i int) bool{
+            return !commits[i].When.Before(start)
+        })
+        to := sort.Search(len(commits), func(i int) bool{
+            return !commits[i].When.Before(end)
+        })
+
+        windows = append(windows, historyWindow{start, end, commits[from:to]})
+        if end.After(last){
+            break
+        }
+    }
+
+    return windows, nil
+}
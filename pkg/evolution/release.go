@@ -0,0 +1,47 @@
+package evolution
+
+import (
+    "fmt"
+
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+)
+
+// Run the release analysis on the repository in path.
+// Outputs the fields of git.AnalyseReleases and,
+// if opts.ReleaseMetrics is not empty, the field
+//   ReleaseMetrics []RevisionMetrics
+// with the listed metrics computed at every release tag.
+// command is used to build the releases for the sct.
+func RunReleaseAnalysis(path, command string, opts util.Options) (map[string]interface{}, error){
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // analyse release tags
+    result, releases, err := git.AnalyseReleases(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    if len(opts.ReleaseMetrics) == 0{
+        return result, nil
+    }
+
+    // compute metrics at every release
+    metrics := make([]RevisionMetrics, 0, len(releases))
+    for i, release := range(releases){
+        util.PrintStatus(fmt.Sprintf("    [%d/%d] analysing release %s", i + 1, len(releases), release.Tag), opts)
+        rev := Revision{Name: release.Tag, Hash: release.Hash, When: release.When}
+        metrics = append(metrics, AnalyseRevision(path, command, rev, opts.ReleaseMetrics, opts))
+    }
+
+    result["ReleaseMetrics"] = metrics
+
+    return result, nil
+}
@@ -0,0 +1,169 @@
+package evolution
+
+import (
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/gct"
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/sct"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+)
+
+// names of the metrics computed per revision
+const (
+    MetricsGit = "git"
+    MetricsSct = "sct"
+    MetricsGct = "gct"
+)
+
+// struct describing an analysed revision
+type Revision struct{
+    Name string  // tag or other label of the revision
+    Hash string  // hash of the commit
+    When time.Time
+}
+
+// struct holding the metrics of an analysed revision
+type RevisionMetrics struct{
+    Revision
+    Git map[string]interface{} `json:",omitempty"`
+    Sct map[string]interface{} `json:",omitempty"`
+    Gct map[string]interface{} `json:",omitempty"`
+    Errors []string `json:",omitempty"`
+}
+
+// Compute the given metrics at the revision rev of the repository in path.
+// For the sct and gct metrics the revision is checked out into a separate
+// worktree, which is built with command for the sct. The native change
+// coupling used without mass-change commits is read from the history of
+// rev in path instead. The range options of opts refer to the analysed
+// head and are ignored for the revision. Failures are recorded
+// in the Errors of the result instead of aborting the analysis.
+func AnalyseRevision(path, command string, rev Revision, metrics []string, opts util.Options) RevisionMetrics{
+    result := RevisionMetrics{Revision: rev}
+    needSct := util.ContainsString(metrics, MetricsSct)
+    needGct := util.ContainsString(metrics, MetricsGct)
+
+    // compute git metrics of the history up to the revision
+    if util.ContainsString(metrics, MetricsGit){
+        util.PrintDebug("running git analysis at " + rev.Name, opts)
+        gitResult, err := analyseGitRevision(path, rev, opts)
+
+        if err != nil{
+            result.Errors = append(result.Errors, "git: " + err.Error())
+        } else{
+            result.Git = gitResult
+        }
+    }
+
+    if !needSct && !needGct{
+        return result
+    }
+
+    // drop the range of the analysed head
+    revOpts := opts
+    revOpts.RangeBase = ""
+    revOpts.Since = time.Time{}
+    revOpts.Until = time.Time{}
+    nativeGct := git.IsIgnoringMassChanges(revOpts)
+
+    // check for outputs of previous runs
+    dir := RevisionDir(path, rev)
+    sctCached := false
+    if needSct && !opts.ForceSct{
+        _, err := sct.LoadSctOutput(dir, revOpts)
+        sctCached = err == nil
+    }
+
+    gctCached := nativeGct
+    if needGct && !nativeGct && !opts.ForceGct{
+        _, err := gct.LoadGctOutput(dir, revOpts)
+        gctCached = err == nil
+    }
+
+    // check out the revision if an analysis has to be run
+    if (needSct && !sctCached) || (needGct && !gctCached){
+        util.PrintDebug("checking out " + rev.Name, opts)
+        err := git.AddWorktree(path, dir, rev.Hash, opts)
+
+        if err != nil{
+            result.Errors = append(result.Errors, "checkout: " + err.Error())
+            return result
+        }
+        defer git.RemoveWorktree(path, dir, opts)
+
+        // build the revision for the sct
+        if needSct && !sctCached && !opts.SkipBuild{
+            util.PrintDebug("building " + rev.Name, opts)
+            err := git.BuildRepo(dir, command, revOpts)
+
+            if err != nil{
+                result.Errors = append(result.Errors, "build: " + err.Error())
+                needSct = false
+            }
+        }
+    }
+
+    // run sct analysis
+    if needSct{
+        util.PrintDebug("running static coupling analysis at " + rev.Name, opts)
+        sctResult, err := sct.RunSctAnalysis(dir, revOpts)
+
+        if err != nil{
+            result.Errors = append(result.Errors, "sct: " + err.Error())
+        } else{
+            result.Sct = sctResult
+        }
+    }
+
+    // run gct analysis
+    if needGct{
+        util.PrintDebug("running git coupling analysis at " + rev.Name, opts)
+        var gctResult map[string]interface{}
+        var err error
+        if nativeGct{
+            nativeOpts := revOpts
+            nativeOpts.Rev = rev.Hash
+            nativeOpts.Refs = []string{git.RefsHead}
+            gctResult, err = gct.RunGctAnalysis(path, nativeOpts)
+        } else{
+            gctResult, err = gct.RunGctAnalysis(dir, revOpts)
+        }
+
+        if err != nil{
+            result.Errors = append(result.Errors, "gct: " + err.Error())
+        } else{
+            result.Gct = gctResult
+        }
+    }
+
+    return result
+}
+
+// Return the worktree directory of the revision rev
+// of the repository in path. The directory name is
+// unique, since sct and gct outputs are stored by name.
+func RevisionDir(path string, rev Revision) string{
+    name := strings.NewReplacer("/", "_", "\\", "_", " ", "_").Replace(rev.Name)
+    return git.WorktreeDir + "/" + filepath.Base(path) + "@" + name
+}
+
+// Compute the git metrics of the history
+// up to the revision rev of the repository in path.
+func analyseGitRevision(path string, rev Revision, opts util.Options) (map[string]interface{}, error){
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    revOpts := opts
+    revOpts.Rev = rev.Hash
+    revOpts.Refs = []string{git.RefsHead}
+
+    return git.AnalyseRepo(repo, revOpts)
+}
@@ -0,0 +1,108 @@
+package evolution
+
+import (
+    "errors"
+    "sort"
+    "strconv"
+
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// revision samplings of the history
+const (
+    SampleTags = "tags"      // every n-th release tag
+    SampleYearly = "yearly"  // last mainline commit of every year
+)
+
+// Select the revisions of repo analysed with the sampling
+// opts.EvolutionSampling and the tag step opts.EvolutionStep.
+// The revisions are ordered by date.
+func SampleRevisions(repo *gogit.Repository, opts util.Options) ([]Revision, error){
+    switch opts.EvolutionSampling{
+    case SampleTags:
+        return sampleTags(repo, opts.EvolutionStep)
+    case SampleYearly:
+        return sampleYears(repo, opts)
+    default:
+        return nil, errors.New("unknown revision sampling: " + opts.EvolutionSampling)
+    }
+}
+
+// Select every step-th release tag of repo,
+// always including the latest release.
+// Pre-releases are ignored.
+func sampleTags(repo *gogit.Repository, step int) ([]Revision, error){
+    releases, err := git.LoadReleases(repo)
+
+    if err != nil{
+        return nil, err
+    }
+
+    if step < 1{
+        step = 1
+    }
+
+    var tags []*git.Release
+    for _, release := range(releases){
+        if !release.Prerelease{
+            tags = append(tags, release)
+        }
+    }
+
+    // sample tags counting back from the latest release
+    var revisions []Revision
+    for i := len(tags) - 1; i >= 0; i -= step{
+        revisions = append(revisions, Revision{Name: tags[i].Tag, Hash: tags[i].Hash, When: tags[i].When})
+    }
+
+    sort.SliceStable(revisions, func(i, j int) bool{
+        return revisions[i].When.Before(revisions[j].When)
+    })
+
+    return revisions, nil
+}
+
+// Select the last commit of every year on
+// the first-parent history of the analysed head.
+func sampleYears(repo *gogit.Repository, opts util.Options) ([]Revision, error){
+    headCommit, err := git.ResolveHead(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // walk the mainline keeping the latest commit per year
+    latest := make(map[int]*object.Commit)
+    for commit := headCommit; commit != nil; {
+        year := commit.Committer.When.UTC().Year()
+        if last, ok := latest[year]; !ok || commit.Committer.When.After(last.Committer.When){
+            latest[year] = commit
+        }
+
+        if commit.NumParents() == 0{
+            break
+        }
+
+        commit, err = commit.Parent(0)
+
+        // stop at parents missing in shallow clones
+        if err != nil{
+            break
+        }
+    }
+
+    revisions := make([]Revision, 0, len(latest))
+    for year, commit := range(latest){
+        revisions = append(revisions, Revision{Name: strconv.Itoa(year), Hash: commit.Hash.String(), When: commit.Committer.When})
+    }
+
+    sort.Slice(revisions, func(i, j int) bool{
+        return revisions[i].Name < revisions[j].Name
+    })
+
+    return revisions, nil
+}
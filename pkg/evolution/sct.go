@@ -0,0 +1,55 @@
+package evolution
+
+import (
+    "fmt"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+)
+
+// Run the sct analysis on revisions of the repository in path
+// sampled with opts.EvolutionSampling. Every revision is checked
+// out into a worktree and built with command.
+// Outputs a map with the following fields:
+//   SctEvolutionSampling    string
+//   SctEvolutionFailedCount int
+//   SctEvolution            []RevisionMetrics
+// Revisions whose build or analysis failed keep their errors
+// in the series instead of aborting it.
+func RunSctEvolution(path, command string, opts util.Options) (map[string]interface{}, error){
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // select revisions
+    revisions, err := SampleRevisions(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // analyse revisions
+    series := make([]RevisionMetrics, 0, len(revisions))
+    failed := 0
+    for i, rev := range(revisions){
+        util.PrintStatus(fmt.Sprintf("    [%d/%d] analysing revision %s", i + 1, len(revisions), rev.Name), opts)
+        metrics := AnalyseRevision(path, command, rev, []string{MetricsSct}, opts)
+
+        if metrics.Sct == nil{
+            failed += 1
+        }
+
+        series = append(series, metrics)
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["SctEvolutionSampling"] = opts.EvolutionSampling
+    result["SctEvolutionFailedCount"] = failed
+    result["SctEvolution"] = series
+
+    return result, nil
+}
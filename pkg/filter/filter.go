@@ -0,0 +1,105 @@
+package filter
+
+import (
+    "path"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// Test if the slash separated path matches the glob pattern.
+// The pattern is matched segment-wise with path.Match, where the
+// segment "**" matches any number of segments. A pattern ending
+// with "/" matches everything below the directory and a pattern
+// without any other "/" matches in any directory, unless
+// it starts with "/", which anchors it at the root.
+func Match(pattern, name string) bool{
+    anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+    if strings.HasSuffix(pattern, "/"){
+        pattern += "**"
+    }
+
+    if !anchored{
+        pattern = "**/" + pattern
+    }
+    pattern = strings.TrimPrefix(pattern, "/")
+
+    return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// Test if the path given by name passes the path filters of opts.
+// If opts.Include is not empty, the path has to match one of its
+// patterns, and it must not match any pattern of opts.Exclude.
+func Included(name string, opts util.Options) bool{
+    name = strings.TrimPrefix(path.Clean(name), "./")
+    if len(opts.Include) > 0 && !matchAny(opts.Include, name){
+        return false
+    }
+
+    return !matchAny(opts.Exclude, name)
+}
+
+// Test if opts contain any path filters.
+func IsFiltering(opts util.Options) bool{
+    return len(opts.Include) > 0 || len(opts.Exclude) > 0
+}
+
+// Describe the path filters of opts for the output.
+// Returns nil if no filters are set.
+func Description(opts util.Options) map[string][]string{
+    if !IsFiltering(opts){
+        return nil
+    }
+
+    return map[string][]string{
+        "Include": opts.Include,
+        "Exclude": opts.Exclude,
+    }
+}
+
+// Test if name matches any of the patterns.
+func matchAny(patterns []string, name string) bool{
+    for _, pattern := range(patterns){
+        if Match(pattern, name){
+            return true
+        }
+    }
+
+    return false
+}
+
+// Match the path segments against the pattern segments.
+func matchSegments(pattern, name []string) bool{
+    for len(pattern) > 0{
+        if pattern[0] == "**"{
+            // try every number of skipped segments
+            for i := 0; i <= len(name); i++{
+                if matchSegments(pattern[1:], name[i:]){
+                    return true
+                }
+            }
+
+            return false
+        }
+
+        if len(name) == 0{
+            return false
+        }
+
+        ok, err := path.Match(pattern[0], name[0])
+        if err != nil || !ok{
+            return false
+        }
+
+        pattern = pattern[1:]
+        name = name[1:]
+    }
+
+    return len(name) == 0
+}
+
+// Escape the glob meta characters of name, so
+// that it can be matched literally by a pattern.
+func Escape(name string) string{
+    return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`).Replace(name)
+}
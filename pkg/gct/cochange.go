@@ -0,0 +1,135 @@
+package gct
+
+import (
+    "errors"
+    "sort"
+    "strconv"
+
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+)
+
+// struct representing an unordered pair of files
+type FilePair struct{
+    A string
+    B string
+}
+
+// Create the pair of the files a and b
+// independent of their order.
+func NewFilePair(a, b string) FilePair{
+    if b < a{
+        a, b = b, a
+    }
+
+    return FilePair{A: a, B: b}
+}
+
+// Count how often the source files of the commits are changed
+// and how often each pair of them is changed in the same commit.
+// Merge commits are ignored.
+func CoChanges(commits []*git.HistoryCommit, opts util.Options) (map[string]int, map[FilePair]int){
+    changes := make(map[string]int)
+    pairs := make(map[FilePair]int)
+    for _, commit := range(commits){
+        // collect changed files
+        var files []string
+        for _, change := range(commit.Changes){
+            if git.IsSourceFile(change.Path, opts){
+                files = append(files, change.Path)
+            }
+        }
+
+        for i, a := range(files){
+            changes[a] += 1
+            for _, b := range(files[i + 1:]){
+                if a != b{
+                    pairs[NewFilePair(a, b)] += 1
+                }
+            }
+        }
+    }
+
+    return changes, pairs
+}
+
+// Create a gct json from the co-changes of files,
+// keeping the pairs changed together at least minWeight times.
+// The edges are weighted with the number of co-changes.
+func CoChangeJson(changes map[string]int, pairs map[FilePair]int, minWeight int) *GctJson{
+    gctJson := new(GctJson)
+
+    // add nodes in sorted order
+    files := make([]string, 0, len(changes))
+    for file := range(changes){
+        files = append(files, file)
+    }
+    sort.Strings(files)
+
+    for _, file := range(files){
+        gctJson.Nodes = append(gctJson.Nodes, GctNode{Id: file})
+    }
+
+    // add edges in sorted order
+    keys := make([]FilePair, 0, len(pairs))
+    for pair, weight := range(pairs){
+        if weight >= minWeight{
+            keys = append(keys, pair)
+        }
+    }
+
+    sort.Slice(keys, func(i, j int) bool{
+        if keys[i].A != keys[j].A{
+            return keys[i].A < keys[j].A
+        }
+
+        return keys[i].B < keys[j].B
+    })
+
+    for i, pair := range(keys){
+        gctJson.Edges = append(gctJson.Edges, GctEdge{
+            Id: strconv.Itoa(i),
+            Start: pair.A,
+            End: pair.B,
+            Weight: float64(pairs[pair]),
+        })
+    }
+
+    return gctJson
+}
+
+// Build the change coupling graph of the repository in path natively
+// from the co-changes in the history selected by opts, as a
+// replacement for the gct output on restricted histories.
+// Mass-change commits selected by opts are left out.
+func NativeGctJson(path string, opts util.Options) (*GctJson, error){
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, err := git.LoadHistory(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, _, err = git.RemoveMassChanges(path, history, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    changes, pairs := CoChanges(history, opts)
+    gctJson := CoChangeJson(changes, pairs, 1)
+
+    // test for empty graph
+    if len(gctJson.Nodes) == 0{
+        return nil, errors.New("empty change coupling graph")
+    }
+
+    return gctJson, nil
+}
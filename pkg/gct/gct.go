@@ -8,6 +8,9 @@ import(
     "os/exec"
 
     "github.com/j-bhm/CppGitMining/pkg/allen"
+    "github.com/j-bhm/CppGitMining/pkg/filter"
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/lang"
     "github.com/j-bhm/CppGitMining/pkg/util"
 )
 
@@ -34,6 +37,12 @@ type GctJson struct{
 // directory for the results of the gct
 const GctOutDir string = util.OutDir + "/gct"
 
+// sources of the change coupling graph
+const (
+    GctSourceGct = "gct"        // output of the GitCouplingTool
+    GctSourceNative = "native"  // raw co-change counts of NativeGctJson
+)
+
 // Run the gct and corresponding analysis on the repository in path
 // and return a map with the following fields:
 //   SumGcd        float64
@@ -41,7 +50,28 @@ const GctOutDir string = util.OutDir + "/gct"
 //   AvgGcd        float64
 //   SizeGct       float64
 //   ComplexityGct float64
+//   GctSource     string
+// The gct always analyses the whole history, so histories restricted
+// to a range or without mass-change commits are analysed with NativeGctJson.
+// GctSource tells which graph was used, as the raw co-change counts
+// of the native graph are not comparable with the gct weights.
+// The output of a previous run is reused if it was created
+// with the language profile selected by opts.
 func RunGctAnalysis(path string, opts util.Options) (map[string]interface{}, error){
+    // use native change coupling on filtered histories
+    if UsesNativeGct(opts){
+        util.PrintDebug("building native change coupling graph", opts)
+        gctJson, err := NativeGctJson(path, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+        
+        gctResult := AnalyseGctOutput(gctJson)
+        gctResult["GctSource"] = GctSourceNative
+        return gctResult, nil
+    }
+    
     // path for gct output
     outputDir := GctOutDir + "/" + filepath.Base(path)
     
@@ -60,12 +90,19 @@ func RunGctAnalysis(path string, opts util.Options) (map[string]interface{}, err
         } else{
             // try parsing old output
             util.PrintDebug("parsing old gct result", opts)
-            gctJson, err := ParseGctOutput(outputDir)
+            gctJson, err := LoadGctOutput(path, opts)
             
             if err == nil{
                 // analyse output
                 util.PrintDebug("using old result for analysis", opts)
+                gctJson, err = FilterGctJson(gctJson, opts)
+                
+                if err != nil{
+                    return nil, err
+                }
+                
                 gctResult := AnalyseGctOutput(gctJson)
+                gctResult["GctSource"] = GctSourceGct
                 
                 // return result
                 return gctResult, nil
@@ -97,6 +134,13 @@ func RunGctAnalysis(path string, opts util.Options) (map[string]interface{}, err
         return nil, err
     }
     
+    // record the language profile of the output
+    err = lang.WriteProfileFile(outputDir, opts)
+    
+    if err != nil{
+        return nil, err
+    }
+    
     // parse the gct output
     util.PrintDebug("parsing gct output", opts)
     gctJson, err := ParseGctOutput(outputDir)
@@ -107,12 +151,26 @@ func RunGctAnalysis(path string, opts util.Options) (map[string]interface{}, err
     
     // analyse gct output
     util.PrintDebug("analysing gct output", opts)
+    gctJson, err = FilterGctJson(gctJson, opts)
+    
+    if err != nil{
+        return nil, err
+    }
+    
     gctResult := AnalyseGctOutput(gctJson)
+    gctResult["GctSource"] = GctSourceGct
     
     // return result
     return gctResult, nil
 }
 
+// Test if the change coupling of the history selected by opts
+// is built with NativeGctJson instead of the gct, which
+// always analyses the whole history.
+func UsesNativeGct(opts util.Options) bool{
+    return git.IsHistoryRestricted(opts) || git.IsIgnoringMassChanges(opts)
+}
+
 // Analyse a gct json and return the result
 // with the following fields:
 //   SumGcd        float64
@@ -154,9 +212,15 @@ func AnalyseGctOutput(gctJson *GctJson) map[string]interface{}{
 // Run the GitCouplingTool on the
 // repository specified in path and
 // save the result in the directory outDir.
+// Only files of the language profile
+// selected by opts are analysed.
 func RunGct(path, outDir string, opts util.Options) error {
     // create command executing sct
-    cmdArgs := []string{path, "-r", "-c", "1", "--file-type", ".c", "--file-type", ".cpp", "--file-type", ".h", "--file-type", ".hpp", "-f", "JSON", "-o", outDir + "/result.json"}
+    cmdArgs := []string{path, "-r", "-c", "1"}
+    for _, ext := range(lang.ForOptions(opts).Extensions()){
+        cmdArgs = append(cmdArgs, "--file-type", ext)
+    }
+    cmdArgs = append(cmdArgs, "-f", "JSON", "-o", outDir + "/result.json")
     cmd := exec.Command(opts.Gct, cmdArgs...)
     
     // run the command
@@ -173,6 +237,8 @@ func RunGct(path, outDir string, opts util.Options) error {
 // Convert the json result from the gct
 // into the graph representation used
 // for analysis.
+// The nodes of the graph are ordered like
+// the node ids returned by uniqueNodeIds.
 func ConvertGctToGraph(gct *GctJson) allen.Graph{
 
     // copy nodes
@@ -192,10 +258,10 @@ func ConvertGctToGraph(gct *GctJson) allen.Graph{
         endNode.InEdges = append(endNode.InEdges, allen.GraphEdge{startNode, edge.Weight})
     }
     
-    // create slice
+    // create slice in node order
     graph := make([]*allen.GraphNode, 0, len(graphMap))
-    for _, node := range(graphMap){
-        graph = append(graph, node)
+    for _, id := range(uniqueNodeIds(gct)){
+        graph = append(graph, graphMap[id])
     }
 
     // return result
@@ -229,3 +295,99 @@ func ParseGctOutput(dir string) (*GctJson, error) {
     // return data
     return gctJson, nil
 }
+
+// Return the node ids of a gct json without
+// duplicates in the order of their first occurrence.
+func uniqueNodeIds(gct *GctJson) []string{
+    seen := make(map[string]bool)
+    ids := make([]string, 0, len(gct.Nodes))
+    for _, node := range(gct.Nodes){
+        if !seen[node.Id]{
+            seen[node.Id] = true
+            ids = append(ids, node.Id)
+        }
+    }
+    
+    return ids
+}
+
+// Calculate the git coupling degree of every node
+// of a gct json and return a map from node ids to degrees.
+func GctNodeDegrees(gct *GctJson) map[string]float64{
+    degrees := allen.SumWeights(ConvertGctToGraph(gct))
+    result := make(map[string]float64)
+    for i, id := range(uniqueNodeIds(gct)){
+        result[id] = degrees[i]
+    }
+    
+    return result
+}
+
+// Remove the nodes of files excluded by the path
+// filters of opts and their edges from a gct json.
+// Returns an error if no nodes remain.
+func FilterGctJson(gctJson *GctJson, opts util.Options) (*GctJson, error){
+    if !filter.IsFiltering(opts){
+        return gctJson, nil
+    }
+    
+    // select nodes
+    result := new(GctJson)
+    kept := make(map[string]bool)
+    for _, node := range(gctJson.Nodes){
+        if filter.Included(filepath.ToSlash(node.Id), opts){
+            kept[node.Id] = true
+            result.Nodes = append(result.Nodes, node)
+        }
+    }
+    
+    // select edges between kept nodes
+    for _, edge := range(gctJson.Edges){
+        if kept[edge.Start] && kept[edge.End]{
+            result.Edges = append(result.Edges, edge)
+        }
+    }
+    
+    if len(result.Nodes) == 0{
+        return nil, errors.New("empty gct graph after path filtering")
+    }
+    
+    return result, nil
+}
+
+// Load the output of a previous gct run
+// on the repository specified in path.
+// Returns an error if the output was created
+// with another language profile than selected by opts.
+func LoadGctOutput(path string, opts util.Options) (*GctJson, error){
+    outputDir := GctOutDir + "/" + filepath.Base(path)
+    err := lang.CheckProfileFile(outputDir, opts)
+    
+    if err != nil{
+        return nil, err
+    }
+    
+    return ParseGctOutput(outputDir)
+}
+
+// Load the filtered gct output of a previous run on the repository
+// in path for analyses building on the change coupling.
+// On restricted histories or without mass-change commits
+// the native change coupling replaces the gct output.
+// Its weights are raw co-change counts and thus not
+// comparable with the weights of the gct output.
+func LoadFilteredGctJson(path string, opts util.Options) (*GctJson, error){
+    var gctJson *GctJson
+    var err error
+    if UsesNativeGct(opts){
+        gctJson, err = NativeGctJson(path, opts)
+    } else{
+        gctJson, err = LoadGctOutput(path, opts)
+    }
+    
+    if err != nil{
+        return nil, err
+    }
+    
+    return FilterGctJson(gctJson, opts)
+}
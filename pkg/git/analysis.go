@@ -1,10 +1,13 @@
 package git
 
 import (
+	"errors"
 	"time"
 	"math"
 	
 	"github.com/j-bhm/CppGitMining/pkg/allen"
+	"github.com/j-bhm/CppGitMining/pkg/filter"
+	"github.com/j-bhm/CppGitMining/pkg/lang"
 	"github.com/j-bhm/CppGitMining/pkg/util"
 	
 	"github.com/go-git/go-git/v5"
@@ -19,11 +22,33 @@ import (
 //   CommitCount           int
 //   BranchCount           int
 //   FileCount             int
+//   SourceFileCount       int
+//   HeaderFileCount       int
+//   TotalFileCount        int
+//   Language              string
 //   Lifetime              time.Duration
 //   GitSize               float64
 //   GitComplexity         float64
 //   AvgContributorCommits float64
 //   AvgBranchCommits      float64
+//   TraversalMode         string
+//   HistoryRange          string (only if the history is restricted)
+// If opts.Blame is set, the fields of AnalyseBlame
+// are added to the result. If opts.Branches is set,
+// the fields of AnalyseBranches are added to the result.
+// If opts.Classify is set, the fields of AnalyseClassification
+// are added to the result. If opts.Szz is set, the fields
+// of AnalyseSzz are added to the result. If opts.Trailers is set,
+// the fields of AnalyseTrailers are added to the result.
+// If opts.DevNetwork is set, the fields of AnalyseDevNetwork
+// are added to the result. If opts.Turnover is set, the fields
+// of AnalyseTurnover are added to the result. If opts.Entropy is set,
+// the fields of AnalyseChangeEntropy are added to the result.
+// If opts.Duplicates is set, the fields of AnalyseDuplicates
+// are added to the result. If mass-change commits are ignored, they are listed in the fields
+//   IgnoredCommitCount int
+//   IgnoredCommits     []IgnoredCommit
+// and left out of the change entropy.
 func RunGitAnalysis(path string, opts util.Options) (map[string]interface{}, error){
     // open the repository
     util.PrintDebug("opening repository", opts)
@@ -40,6 +65,115 @@ func RunGitAnalysis(path string, opts util.Options) (map[string]interface{}, err
         return nil, err
     }
     
+    // run branch topology analysis
+    if opts.Branches{
+        branchResult, err := AnalyseBranches(repo, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+        
+        mergeResult(result, branchResult)
+    }
+    
+    // run trailer analysis
+    if opts.Trailers{
+        trailerResult, err := AnalyseTrailers(repo, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+        
+        mergeResult(result, trailerResult)
+    }
+    
+    // load history for history based analyses
+    var history []*HistoryCommit
+    if opts.Classify || opts.Szz || opts.DevNetwork || opts.Turnover || opts.Entropy || opts.Duplicates || IsIgnoringMassChanges(opts){
+        history, err = LoadHistory(repo, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+    }
+    
+    // remove mass-change commits from the churn history
+    churnHistory, ignored, err := RemoveMassChanges(path, history, opts)
+    
+    if err != nil{
+        return nil, err
+    }
+    
+    if IsIgnoringMassChanges(opts){
+        result["IgnoredCommitCount"] = len(ignored)
+        result["IgnoredCommits"] = ignored
+    }
+    
+    // run commit classification
+    if opts.Classify{
+        classResult, err := AnalyseClassification(history, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+        
+        mergeResult(result, classResult)
+    }
+    
+    // run szz analysis
+    if opts.Szz{
+        szzResult, err := AnalyseSzz(path, history, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+        
+        mergeResult(result, szzResult)
+    }
+    
+    // run developer network analysis
+    if opts.DevNetwork{
+        mergeResult(result, AnalyseDevNetwork(history, opts))
+    }
+    
+    // run turnover analysis
+    if opts.Turnover{
+        headFiles, err := HeadFiles(repo, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+        
+        mergeResult(result, AnalyseTurnover(history, headFiles, opts))
+    }
+    
+    // run change entropy analysis
+    if opts.Entropy{
+        headFiles, err := HeadFiles(repo, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+        
+        mergeResult(result, AnalyseChangeEntropy(churnHistory, headFiles, opts))
+    }
+    
+    // run duplicate and revert detection
+    if opts.Duplicates{
+        mergeResult(result, AnalyseDuplicates(history, opts))
+    }
+    
+    // run blame analysis
+    if opts.Blame{
+        blameResult, err := AnalyseBlame(path, opts)
+        
+        if err != nil{
+            return nil, err
+        }
+        
+        mergeResult(result, blameResult)
+    }
+    
     // return the result
     return result, nil
 }
@@ -51,29 +185,27 @@ func RunGitAnalysis(path string, opts util.Options) (map[string]interface{}, err
 //   CommitCount           int
 //   BranchCount           int
 //   FileCount             int
+//   SourceFileCount       int
+//   HeaderFileCount       int
+//   TotalFileCount        int
+//   Language              string
 //   Lifetime              time.Duration
 //   GitSize               float64
 //   GitComplexity         float64
 //   AvgContributorCommits float64
 //   AvgBranchCommits      float64
+//   TraversalMode         string
+//   HistoryRange          string (only if the history is restricted)
+// The commits are selected by opts.Refs and opts.FirstParent
+// and restricted to the history range of opts,
+// files are counted at the revision given by ResolveHead.
+// FileCount only counts the source and header files of the
+// language profile, TotalFileCount counts all files.
+// Files excluded by the path filters are not counted.
 func AnalyseRepo(repo *git.Repository, opts util.Options) (map[string]interface{}, error){
-    // get iterater over commits
-    util.PrintDebug("analysing repository", opts)
-    commitIter, err := repo.CommitObjects()
-    
-    if err != nil{
-        return nil, err
-    }
-    
-    // get head reference
-    headRef, err := repo.Head()
-    
-    if err != nil{
-        return nil, err
-    }
-    
     // get head commit
-    headCommit, err := repo.CommitObject(headRef.Hash())
+    util.PrintDebug("analysing repository", opts)
+    headCommit, err := ResolveHead(repo, opts)
     
     if err != nil{
         return nil, err
@@ -86,10 +218,23 @@ func AnalyseRepo(repo *git.Repository, opts util.Options) (map[string]interface{
         return nil, err
     }
     
-    // count files
-    fileCount := 0
+    // count files of the language profile
+    profile := lang.ForOptions(opts)
+    totalFileCount := 0
+    sourceFileCount := 0
+    headerFileCount := 0
     fileIter.ForEach(func(file *object.File) error{
-        fileCount += 1
+        if !filter.Included(file.Name, opts){
+            return nil
+        }
+        
+        totalFileCount += 1
+        if profile.IsSource(file.Name){
+            sourceFileCount += 1
+        } else if profile.IsHeader(file.Name){
+            headerFileCount += 1
+        }
+        
         return nil
     })
     
@@ -104,7 +249,7 @@ func AnalyseRepo(repo *git.Repository, opts util.Options) (map[string]interface{
     
     // iterate over commits
     first := true
-    err = commitIter.ForEach(func(commit *object.Commit) error{
+    err = ForEachCommit(repo, opts, func(commit *object.Commit) error{
         // set time variables
         if first{
             firstCommit = commit.Author.When
@@ -138,15 +283,14 @@ func AnalyseRepo(repo *git.Repository, opts util.Options) (map[string]interface{
             nodes[commit.Hash] = node
         }
         
-        // iterate over commit parents
-        parentIter := commit.Parents()
-        err = parentIter.ForEach(func(parent *object.Commit) error{
+        // iterate over traversed commit parents
+        for _, parent := range(TraversedParents(commit, opts)){
             // get/create parent node
-            parentNode := nodes[parent.Hash]
+            parentNode := nodes[parent]
             
             if parentNode == nil{
                 parentNode = new(allen.GraphNode)
-                nodes[parent.Hash] = parentNode
+                nodes[parent] = parentNode
             }
             
             // add edge to commit graph
@@ -156,25 +300,27 @@ func AnalyseRepo(repo *git.Repository, opts util.Options) (map[string]interface{
             // update parent tracker on non-merge commits
             if(noMerge){
                 // test if parent has not been set yet
-                if !parents[parent.ID()]{
+                if !parents[parent]{
                     // set parent as seen
-                    parents[parent.ID()] = true
+                    parents[parent] = true
                 } else{
                     // increase branch counter
                     branchCount += 1
                 }
             }
-            
-            return nil
-        })
+        }
         
-        return err
+        return nil
     })
     
     if err != nil{
         return nil, err
     }
     
+    if commitCount == 0{
+        return nil, errors.New("no commits in the analysed history")
+    }
+    
     // calculate commit graph complexity and size
     var commitGraph allen.Graph
     for _, v := range(nodes){
@@ -199,12 +345,20 @@ func AnalyseRepo(repo *git.Repository, opts util.Options) (map[string]interface{
     result["ContributorEntropy"] = authorEntropy
     result["CommitCount"] = commitCount
     result["BranchCount"] = branchCount
-    result["FileCount"] = fileCount
+    result["FileCount"] = sourceFileCount + headerFileCount
+    result["SourceFileCount"] = sourceFileCount
+    result["HeaderFileCount"] = headerFileCount
+    result["TotalFileCount"] = totalFileCount
+    result["Language"] = profile.Name
     result["Lifetime"] = lastCommit.Sub(firstCommit).Hours()
     result["GitSize"] = commitSize
     result["GitComplexity"] = commitComplexity
     result["AvgContributorCommits"] = float64(commitCount) / float64(authorCount)
     result["AvgBranchCommits"] = float64(commitCount) / float64(branchCount)
+    result["TraversalMode"] = TraversalMode(opts)
+    if IsHistoryRestricted(opts){
+        result["HistoryRange"] = HistoryRange(opts)
+    }
     
     // return result
     return result, nil
@@ -0,0 +1,225 @@
+package git
+
+import (
+    "sync"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// struct describing the dominant author of a file
+type FileOwner struct{
+    Author string
+    Share float64
+}
+
+// struct holding the blame of a single file
+type fileBlame struct{
+    path string
+    authors map[string]int // maps authors to their number of lines
+    ages []float64         // age of every line in hours
+}
+
+// Run a line-level blame on all source files
+// of the analysed head revision of the repository in path.
+// Outputs a map with the following fields:
+//   BlameFileCount         int
+//   BlameSkippedFileCount  int
+//   BlameLineCount         int
+//   BlameAuthorCount       int
+//   BlameAuthorShares      map[string]float64
+//   BlameMedianLineAge     float64
+//   BlameOldLinePercentage float64
+//   BlameFileOwners        map[string]FileOwner
+func AnalyseBlame(path string, opts util.Options) (map[string]interface{}, error){
+    // open the repository
+    util.PrintDebug("running blame analysis", opts)
+    repo, err := git.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // get head commit
+    headCommit, err := ResolveHead(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // collect files to blame
+    var files []string
+    skipped := 0
+    fileIter, err := headCommit.Files()
+
+    if err != nil{
+        return nil, err
+    }
+
+    err = fileIter.ForEach(func(file *object.File) error{
+        if !IsSourceFile(file.Name, opts){
+            return nil
+        }
+
+        // skip files exceeding the size cap
+        if opts.BlameMaxSize > 0 && file.Size > opts.BlameMaxSize{
+            skipped += 1
+            return nil
+        }
+
+        files = append(files, file.Name)
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    // blame files in parallel
+    blames, err := blameFiles(path, headCommit.Hash, headCommit.Committer.When, files, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // aggregate file blames
+    skipped += len(files) - len(blames)
+    lineCount := 0
+    authors := make(map[string]int)
+    var ages []float64
+    owners := make(map[string]FileOwner)
+    for _, b := range(blames){
+        // find dominant author of the file
+        fileLines := len(b.ages)
+        var owner FileOwner
+        for author, lines := range(b.authors){
+            authors[author] += lines
+            share := float64(lines) / float64(fileLines)
+            if share > owner.Share || (share == owner.Share && author < owner.Author){
+                owner = FileOwner{Author: author, Share: share}
+            }
+        }
+
+        if fileLines > 0{
+            owners[b.path] = owner
+        }
+
+        lineCount += fileLines
+        ages = append(ages, b.ages...)
+    }
+
+    // calculate author shares
+    shares := make(map[string]float64)
+    for author, lines := range(authors){
+        shares[author] = float64(lines) / float64(lineCount)
+    }
+
+    // count lines older than the age threshold
+    threshold := float64(opts.BlameAgeYears) * 365.25 * 24
+    oldLines := 0
+    for _, age := range(ages){
+        if age > threshold{
+            oldLines += 1
+        }
+    }
+
+    oldPercentage := 0.0
+    if lineCount > 0{
+        oldPercentage = 100 * float64(oldLines) / float64(lineCount)
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["BlameFileCount"] = len(blames)
+    result["BlameSkippedFileCount"] = skipped
+    result["BlameLineCount"] = lineCount
+    result["BlameAuthorCount"] = len(authors)
+    result["BlameAuthorShares"] = shares
+    result["BlameMedianLineAge"] = util.Median(ages)
+    result["BlameOldLinePercentage"] = oldPercentage
+    result["BlameFileOwners"] = owners
+
+    // return result
+    return result, nil
+}
+
+// Blame the given files at the commit rev of the
+// repository in path using opts.Jobs workers.
+// Line ages are measured relative to now.
+// Files for which the blame fails are left out.
+func blameFiles(path string, rev plumbing.Hash, now time.Time, files []string, opts util.Options) ([]*fileBlame, error){
+    var mutex sync.Mutex
+    var blames []*fileBlame
+    names := make(map[*git.Repository]map[plumbing.Hash]string)
+    err := forEachParallel(path, len(files), opts, func(repo *git.Repository, i int){
+        file := files[i]
+        util.PrintDebug("blaming " + file, opts)
+        blameResult, err := blameFile(repo, rev, file)
+
+        if err != nil{
+            util.PrintDebug("blame failed: " + err.Error(), opts)
+            return
+        }
+
+        // get the author name cache of the worker
+        mutex.Lock()
+        cache := names[repo]
+        if cache == nil{
+            cache = make(map[plumbing.Hash]string)
+            names[repo] = cache
+        }
+        mutex.Unlock()
+
+        // collect authors and ages of the lines
+        b := &fileBlame{path: file, authors: make(map[string]int)}
+        for _, line := range(blameResult.Lines){
+            b.authors[lineAuthor(repo, line, cache)] += 1
+            b.ages = append(b.ages, now.Sub(line.Date).Hours())
+        }
+
+        mutex.Lock()
+        blames = append(blames, b)
+        mutex.Unlock()
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    return blames, nil
+}
+
+// Blame the file in path at the commit rev of repo.
+func blameFile(repo *git.Repository, rev plumbing.Hash, path string) (*git.BlameResult, error){
+    commit, err := repo.CommitObject(rev)
+
+    if err != nil{
+        return nil, err
+    }
+
+    return git.Blame(commit, path)
+}
+
+// Return the name of the author of the commit that introduced line.
+// Names are cached by commit hash in cache.
+func lineAuthor(repo *git.Repository, line *git.Line, cache map[plumbing.Hash]string) string{
+    name, ok := cache[line.Hash]
+    if ok{
+        return name
+    }
+
+    // fall back to the email address blame provides
+    name = line.Author
+    commit, err := repo.CommitObject(line.Hash)
+
+    if err == nil{
+        name = commit.Author.Name
+    }
+
+    cache[line.Hash] = name
+    return name
+}
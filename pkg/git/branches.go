@@ -0,0 +1,353 @@
+package git
+
+import (
+    "container/heap"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// struct describing a branch that was merged by a merge commit
+type MergedBranch struct{
+    Merge string          // hash of the merge commit
+    Commits int           // number of commits on the branch
+    Lifetime float64      // time between first and last branch commit in hours
+    MergeLatency float64  // time between fork point and merge in hours
+}
+
+// node of the in-memory commit graph
+type commitNode struct{
+    hash plumbing.Hash
+    parents []plumbing.Hash
+    children int
+    authored time.Time
+    committed time.Time
+    message string
+}
+
+// matches subjects of squash merges created by code hosting platforms
+var squashRegexp = regexp.MustCompile(`\(#[0-9]+\)\s*$`)
+
+// minimum difference between author and committer date of a rebased commit
+const rebaseThreshold = time.Hour
+
+// Analyse the branch and merge topology of the git repository repo.
+// Outputs a map with the following fields:
+//   LocalBranchCount     int
+//   RemoteBranchCount    int
+//   TagCount             int
+//   ForkPointCount       int
+//   MergePointCount      int
+//   MergeCommitRatio     float64
+//   MergedBranchCount    int
+//   AvgBranchLifetime    float64
+//   MedianBranchLifetime float64
+//   AvgMergeLatency      float64
+//   MedianMergeLatency   float64
+//   SquashMergeCount     int
+//   SquashMergeRatio     float64
+//   RebasedCommitRatio   float64
+//   BranchWorkflow       string
+//   MergedBranches       []MergedBranch
+// BranchWorkflow is one of "squash", "rebase", "merge" or "linear".
+func AnalyseBranches(repo *git.Repository, opts util.Options) (map[string]interface{}, error){
+    // count references
+    util.PrintDebug("analysing branch topology", opts)
+    refIter, err := repo.References()
+
+    if err != nil{
+        return nil, err
+    }
+
+    localCount := 0
+    remoteCount := 0
+    tagCount := 0
+    err = refIter.ForEach(func(ref *plumbing.Reference) error{
+        // skip symbolic references like HEAD
+        if ref.Type() != plumbing.HashReference{
+            return nil
+        }
+
+        name := ref.Name()
+        if name.IsBranch(){
+            localCount += 1
+        } else if name.IsRemote(){
+            remoteCount += 1
+        } else if name.IsTag(){
+            tagCount += 1
+        }
+
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    // build the commit graph
+    graph, err := loadCommitGraph(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // count fork points, merges, squash merges and rebased commits
+    forkCount := 0
+    var merges []*commitNode
+    squashCount := 0
+    rebasedCount := 0
+    for _, node := range(graph){
+        if node.children > 1{
+            forkCount += 1
+        }
+
+        if len(node.parents) > 1{
+            merges = append(merges, node)
+            continue
+        }
+
+        if isSquashMerge(node.message){
+            squashCount += 1
+        }
+
+        if node.committed.Sub(node.authored) > rebaseThreshold{
+            rebasedCount += 1
+        }
+    }
+
+    // measure the branches merged by every merge commit
+    var branches []MergedBranch
+    var lifetimes []float64
+    var latencies []float64
+    for _, merge := range(merges){
+        for _, parent := range(merge.parents[1:]){
+            branch, ok := measureMergedBranch(graph, merge, merge.parents[0], parent)
+            if !ok{
+                continue
+            }
+
+            branches = append(branches, branch)
+            lifetimes = append(lifetimes, branch.Lifetime)
+            latencies = append(latencies, branch.MergeLatency)
+        }
+    }
+
+    // calculate ratios
+    commitCount := len(graph)
+    noMergeCount := commitCount - len(merges)
+    mergeRatio := 0.0
+    if commitCount > 0{
+        mergeRatio = float64(len(merges)) / float64(commitCount)
+    }
+
+    squashRatio := 0.0
+    rebasedRatio := 0.0
+    if noMergeCount > 0{
+        squashRatio = float64(squashCount) / float64(noMergeCount)
+        rebasedRatio = float64(rebasedCount) / float64(noMergeCount)
+    }
+
+    // classify the workflow
+    workflow := "linear"
+    if squashRatio >= 0.5{
+        workflow = "squash"
+    } else if mergeRatio >= 0.05{
+        workflow = "merge"
+    } else if rebasedRatio >= 0.5{
+        workflow = "rebase"
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["LocalBranchCount"] = localCount
+    result["RemoteBranchCount"] = remoteCount
+    result["TagCount"] = tagCount
+    result["ForkPointCount"] = forkCount
+    result["MergePointCount"] = len(merges)
+    result["MergeCommitRatio"] = mergeRatio
+    result["MergedBranchCount"] = len(branches)
+    result["AvgBranchLifetime"] = util.Mean(lifetimes)
+    result["MedianBranchLifetime"] = util.Median(lifetimes)
+    result["AvgMergeLatency"] = util.Mean(latencies)
+    result["MedianMergeLatency"] = util.Median(latencies)
+    result["SquashMergeCount"] = squashCount
+    result["SquashMergeRatio"] = squashRatio
+    result["RebasedCommitRatio"] = rebasedRatio
+    result["BranchWorkflow"] = workflow
+    result["MergedBranches"] = branches
+
+    // return result
+    return result, nil
+}
+
+// Load the graph of the commits of the git repository repo
+// selected by the traversal options.
+func loadCommitGraph(repo *git.Repository, opts util.Options) (map[plumbing.Hash]*commitNode, error){
+    graph := make(map[plumbing.Hash]*commitNode)
+    err := ForEachCommit(repo, opts, func(commit *object.Commit) error{
+        graph[commit.Hash] = &commitNode{
+            hash: commit.Hash,
+            parents: commit.ParentHashes,
+            authored: commit.Author.When,
+            committed: commit.Committer.When,
+            message: commit.Message,
+        }
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    // count children
+    for _, node := range(graph){
+        for _, parent := range(node.parents){
+            parentNode := graph[parent]
+            if parentNode != nil{
+                parentNode.children += 1
+            }
+        }
+    }
+
+    return graph, nil
+}
+
+// Test if the commit message belongs to a squash merge.
+func isSquashMerge(message string) bool {
+    subject := strings.SplitN(message, "\n", 2)[0]
+    return squashRegexp.MatchString(subject) || strings.Contains(message, "Squashed commit of the following")
+}
+
+// Measure the branch with tip branch that is merged
+// into main by the commit merge.
+// Returns false if the branch has no own commits.
+func measureMergedBranch(graph map[plumbing.Hash]*commitNode, merge *commitNode, main, branch plumbing.Hash) (MergedBranch, bool){
+    commits, bases := paintDownToCommon(graph, main, branch)
+    if len(commits) == 0{
+        return MergedBranch{}, false
+    }
+
+    // find first and last branch commit
+    first := commits[0].authored
+    last := commits[0].authored
+    for _, commit := range(commits){
+        if commit.authored.Before(first){
+            first = commit.authored
+        }
+
+        if commit.authored.After(last){
+            last = commit.authored
+        }
+    }
+
+    // find the latest fork point
+    fork := first
+    for i, base := range(bases){
+        if i == 0 || base.authored.After(fork){
+            fork = base.authored
+        }
+    }
+
+    return MergedBranch{
+        Merge: merge.hash.String(),
+        Commits: len(commits),
+        Lifetime: last.Sub(first).Hours(),
+        MergeLatency: merge.authored.Sub(fork).Hours(),
+    }, true
+}
+
+// flags used to paint the commit graph
+const (
+    paintMain = 1 << iota
+    paintBranch
+    paintStale
+)
+
+// entry of the commit queue ordered by commit time
+type paintEntry struct{
+    node *commitNode
+    flags int
+}
+
+// queue of commits with the newest commit first
+type paintQueue []paintEntry
+
+func (q paintQueue) Len() int { return len(q) }
+func (q paintQueue) Less(i, j int) bool { return q[i].node.committed.After(q[j].node.committed) }
+func (q paintQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *paintQueue) Push(x interface{}) { *q = append(*q, x.(paintEntry)) }
+func (q *paintQueue) Pop() interface{} {
+    old := *q
+    entry := old[len(old) - 1]
+    *q = old[:len(old) - 1]
+    return entry
+}
+
+// Walk the commit graph from main and branch
+// in commit time order like git merge-base.
+// Returns the commits only reachable from branch
+// and the merge bases of main and branch.
+func paintDownToCommon(graph map[plumbing.Hash]*commitNode, main, branch plumbing.Hash) (commits []*commitNode, bases []*commitNode){
+    paint := make(map[plumbing.Hash]int)
+    queue := &paintQueue{}
+    active := 0
+
+    // add a commit with the given flags to the queue
+    push := func(hash plumbing.Hash, flags int){
+        node := graph[hash]
+        if node == nil || paint[hash] & flags == flags{
+            return
+        }
+
+        paint[hash] |= flags
+        heap.Push(queue, paintEntry{node, paint[hash]})
+        if paint[hash] & paintStale == 0{
+            active += 1
+        }
+    }
+
+    push(main, paintMain)
+    push(branch, paintBranch)
+
+    // walk until only stale commits are left
+    visited := make(map[plumbing.Hash]int)
+    for active > 0{
+        entry := heap.Pop(queue).(paintEntry)
+        if entry.flags & paintStale == 0{
+            active -= 1
+        }
+
+        // skip commits already processed with the same flags
+        node := entry.node
+        flags := paint[node.hash]
+        if visited[node.hash] == flags{
+            continue
+        }
+        visited[node.hash] = flags
+
+        if flags & (paintMain | paintBranch) == paintMain | paintBranch{
+            // commit is reachable from both tips
+            if flags & paintStale == 0{
+                bases = append(bases, node)
+                flags |= paintStale
+                paint[node.hash] = flags
+            }
+        } else if flags == paintBranch{
+            // commit is only reachable from the branch
+            commits = append(commits, node)
+        }
+
+        // paint parents
+        for _, parent := range(node.parents){
+            push(parent, flags)
+        }
+    }
+
+    return commits, bases
+}
@@ -0,0 +1,218 @@
+package git
+
+import (
+    "bufio"
+    "errors"
+    "os"
+    "path"
+    "regexp"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// commit classes
+const (
+    ClassMerge = "merge"
+    ClassRevert = "revert"
+    ClassFormatting = "formatting"
+    ClassDocumentation = "documentation"
+    ClassCorrective = "corrective"
+    ClassRefactoring = "refactoring"
+    ClassFeature = "feature"
+    ClassOther = "other"
+)
+
+// struct describing a keyword rule assigning a class to commit messages
+type ClassRule struct{
+    Class string
+    Keyword *regexp.Regexp
+}
+
+// default keyword rules, evaluated in order
+var defaultClassRules = []ClassRule{
+    {ClassCorrective, regexp.MustCompile(`(?i)\b(fix(e[sd])?|fixing|bugs?|bugfix|crash(es|ed)?|defects?|faults?|regressions?|hotfix)\b`)},
+    {ClassCorrective, regexp.MustCompile(`(?i)\b(close[sd]?|resolve[sd]?|fix(e[sd])?) #[0-9]+`)},
+    {ClassRefactoring, regexp.MustCompile(`(?i)\b(refactor\w*|clean ?up\w*|restructur\w*|renam\w*|simplif\w*|reorganiz\w*|tidy)\b`)},
+    {ClassFeature, regexp.MustCompile(`(?i)\b(add(s|ed|ing)?|implement\w*|introduc\w*|support\w*|new|features?)\b`)},
+}
+
+// matches messages of commits created by git revert
+var revertRegexp = regexp.MustCompile(`(?i)^revert\b|This reverts commit [0-9a-f]+`)
+
+// file extensions of documentation files
+var docExtensions = []string{".md", ".txt", ".rst", ".adoc", ".dox", ".texi", ".html"}
+
+// Load keyword rules from the file at path.
+// Every non-empty line not starting with '#' has the form
+//   <class>: <regular expression>
+// and the expressions are matched case-insensitively
+// against the commit message. Classes given in the file
+// replace the default rules of the same class, other
+// default rules are kept.
+func LoadClassRules(path string) ([]ClassRule, error){
+    // open file
+    file, err := os.Open(path)
+
+    if err != nil{
+        return nil, err
+    }
+    defer file.Close()
+
+    // parse rules
+    var rules []ClassRule
+    replaced := make(map[string]bool)
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan(){
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#"){
+            continue
+        }
+
+        class, keyword, ok := strings.Cut(line, ":")
+        if !ok{
+            return nil, errors.New("parsing " + path + ": missing class in line: " + line)
+        }
+
+        class = strings.TrimSpace(class)
+        re, err := regexp.Compile("(?i)" + strings.TrimSpace(keyword))
+
+        if err != nil{
+            return nil, errors.New("parsing " + path + ": " + err.Error())
+        }
+
+        rules = append(rules, ClassRule{class, re})
+        replaced[class] = true
+    }
+
+    if err := scanner.Err(); err != nil{
+        return nil, err
+    }
+
+    // keep default rules of classes not given in the file
+    for _, rule := range(defaultClassRules){
+        if !replaced[rule.Class]{
+            rules = append(rules, rule)
+        }
+    }
+
+    return rules, nil
+}
+
+// Classify commit using the keyword rules.
+// Merges, reverts, whitespace-only and documentation-only
+// commits are recognised first, then the rules are evaluated
+// in order and the first match determines the class.
+func ClassifyCommit(commit *HistoryCommit, rules []ClassRule) string{
+    if len(commit.Parents) > 1{
+        return ClassMerge
+    }
+
+    if revertRegexp.MatchString(commit.Message){
+        return ClassRevert
+    }
+
+    // classify by diff shape
+    if len(commit.Changes) > 0{
+        formatting := true
+        documentation := true
+        for _, change := range(commit.Changes){
+            formatting = formatting && change.Whitespace
+            documentation = documentation && isDocFile(change.Path)
+        }
+
+        if formatting{
+            return ClassFormatting
+        }
+
+        if documentation{
+            return ClassDocumentation
+        }
+    }
+
+    // classify by message
+    for _, rule := range(rules){
+        if rule.Keyword.MatchString(commit.Message){
+            return rule.Class
+        }
+    }
+
+    return ClassOther
+}
+
+// Classify all commits of history.
+// Outputs a map with the following fields:
+//   CommitClassShares map[string]float64
+//   BugFixRatio       float64
+//   FileFixCounts     map[string]int
+// The keyword rules are loaded from opts.ClassifyKeywords if set.
+// BugFixRatio is the share of corrective commits among the non-merge
+// commits. FileFixCounts counts the corrective commits changing each
+// source file.
+func AnalyseClassification(history []*HistoryCommit, opts util.Options) (map[string]interface{}, error){
+    // load rules
+    util.PrintDebug("classifying commits", opts)
+    rules, err := classRules(opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // classify commits
+    counts := make(map[string]int)
+    fileFixes := make(map[string]int)
+    for _, commit := range(history){
+        class := ClassifyCommit(commit, rules)
+        counts[class] += 1
+
+        if class == ClassCorrective{
+            for _, change := range(commit.Changes){
+                if IsSourceFile(change.Path, opts){
+                    fileFixes[change.Path] += 1
+                }
+            }
+        }
+    }
+
+    // calculate shares
+    shares := make(map[string]float64)
+    for class, count := range(counts){
+        shares[class] = float64(count) / float64(len(history))
+    }
+
+    // merges can never be fixes, so leave them out of the ratio
+    bugFixRatio := 0.0
+    if len(history) > counts[ClassMerge]{
+        bugFixRatio = float64(counts[ClassCorrective]) / float64(len(history) - counts[ClassMerge])
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["CommitClassShares"] = shares
+    result["BugFixRatio"] = bugFixRatio
+    result["FileFixCounts"] = fileFixes
+
+    // return result
+    return result, nil
+}
+
+// Return the keyword rules selected by opts.ClassifyKeywords.
+func classRules(opts util.Options) ([]ClassRule, error){
+    if opts.ClassifyKeywords == ""{
+        return defaultClassRules, nil
+    }
+
+    return LoadClassRules(opts.ClassifyKeywords)
+}
+
+// Test if the file given by name is a documentation file.
+func isDocFile(name string) bool {
+    ext := strings.ToLower(path.Ext(name))
+    for _, docExt := range(docExtensions){
+        if ext == docExt{
+            return true
+        }
+    }
+
+    return strings.HasPrefix(name, "doc/") || strings.HasPrefix(name, "docs/") || strings.Contains(name, "/doc/") || strings.Contains(name, "/docs/")
+}
@@ -0,0 +1,136 @@
+package git
+
+import (
+    "regexp"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// matches the reference of commits created by git revert
+var revertedRegexp = regexp.MustCompile(`This reverts commit ([0-9a-f]{7,40})`)
+
+// matches the subject of commits created by git revert
+var revertSubjectRegexp = regexp.MustCompile(`^Revert "(.*)"$`)
+
+// matches the line added by git cherry-pick -x
+var cherryPickRegexp = regexp.MustCompile(`\(cherry picked from commit ([0-9a-f]{7,40})\)`)
+
+// struct describing a revert and the commit it reverts
+type RevertPair struct{
+    Revert string
+    Reverted string
+}
+
+// Detect duplicate patches, cherry-picks and reverts in history.
+// Of the non-merge commits sharing a patch id, all but the original
+// are duplicates. Since cherry-picks keep the author date, the original
+// is the first commit without a "cherry picked from commit" line.
+// Commits with such a line or duplicates are backports. Reverts are
+// recognised by their message and matched to the reverted commit
+// by its hash or subject.
+// Outputs a map with the following fields:
+//   DuplicateCommitCount    int
+//   DeduplicatedCommitCount int
+//   CherryPickCount         int
+//   BackportCommitCount     int
+//   BackportLineCount       int
+//   RevertCount             int
+//   RevertRatio             float64
+//   MatchedRevertCount      int
+//   RevertPairs             []RevertPair
+func AnalyseDuplicates(history []*HistoryCommit, opts util.Options) map[string]interface{}{
+    util.PrintDebug("detecting duplicate patches and reverts", opts)
+
+    // select the original commit of every patch id
+    originals := make(map[string]*HistoryCommit)
+    for _, commit := range(history){
+        if len(commit.Parents) > 1 || commit.PatchId == ""{
+            continue
+        }
+
+        original := originals[commit.PatchId]
+        if original == nil || (cherryPickRegexp.MatchString(original.Message) && !cherryPickRegexp.MatchString(commit.Message)){
+            originals[commit.PatchId] = commit
+        }
+    }
+
+    subjects := make(map[string]string)
+    hashes := make(map[string]bool)
+    commitCount := 0
+    duplicates := 0
+    cherryPicks := 0
+    backports := 0
+    backportLines := 0
+    reverts := 0
+    var pairs []RevertPair
+    for _, commit := range(history){
+        if len(commit.Parents) > 1{
+            continue
+        }
+        commitCount += 1
+        hash := commit.Hash.String()
+
+        // detect duplicate patches
+        duplicate := commit.PatchId != "" && originals[commit.PatchId] != commit
+        if duplicate{
+            duplicates += 1
+        }
+
+        // detect backports
+        cherryPick := cherryPickRegexp.MatchString(commit.Message)
+        if cherryPick{
+            cherryPicks += 1
+        }
+
+        if duplicate || cherryPick{
+            backports += 1
+            for _, change := range(commit.Changes){
+                backportLines += change.Additions + change.Deletions
+            }
+        }
+
+        // detect reverts and match the reverted commit
+        subject := strings.TrimSpace(strings.SplitN(commit.Message, "\n", 2)[0])
+        if revertRegexp.MatchString(commit.Message){
+            reverts += 1
+            reverted := ""
+            if match := revertedRegexp.FindStringSubmatch(commit.Message); match != nil{
+                for h := range(hashes){
+                    if strings.HasPrefix(h, match[1]){
+                        reverted = h
+                        break
+                    }
+                }
+            } else if match := revertSubjectRegexp.FindStringSubmatch(subject); match != nil{
+                reverted = subjects[match[1]]
+            }
+
+            if reverted != ""{
+                pairs = append(pairs, RevertPair{Revert: hash, Reverted: reverted})
+            }
+        }
+
+        hashes[hash] = true
+        subjects[subject] = hash
+    }
+
+    // set result values
+    revertRatio := 0.0
+    if commitCount > 0{
+        revertRatio = float64(reverts) / float64(commitCount)
+    }
+
+    result := make(map[string]interface{})
+    result["DuplicateCommitCount"] = duplicates
+    result["DeduplicatedCommitCount"] = commitCount - duplicates
+    result["CherryPickCount"] = cherryPicks
+    result["BackportCommitCount"] = backports
+    result["BackportLineCount"] = backportLines
+    result["RevertCount"] = reverts
+    result["RevertRatio"] = revertRatio
+    result["MatchedRevertCount"] = len(pairs)
+    result["RevertPairs"] = pairs
+
+    return result
+}
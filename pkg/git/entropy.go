@@ -0,0 +1,130 @@
+package git
+
+import (
+    "math"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// struct describing the change entropy of a period
+type EntropyPeriod struct{
+    Start time.Time
+    End time.Time
+    Changes int                // number of file changes
+    Entropy float64            // entropy of the change distribution
+    NormalisedEntropy float64  // entropy normalised by the number of source files at head
+}
+
+// Calculate the change entropy after Hassan in history.
+// The history is split into periods of opts.EntropyPeriodDays days or,
+// if opts.EntropyBurstHours is set, into bursts of commits separated by
+// gaps of at least opts.EntropyBurstHours hours. Only changes of source
+// files are considered and the entropy of a period is normalised by the
+// number of source files in headFiles, the files at the analysed head,
+// so that files untouched in a restricted history are counted as well.
+// Outputs a map with the following fields:
+//   ChangeEntropyPeriods  []EntropyPeriod
+//   AvgChangeEntropy      float64
+//   MaxChangeEntropy      float64
+//   FileHistoryComplexity map[string]float64
+// AvgChangeEntropy and MaxChangeEntropy use the normalised entropies.
+// The history complexity of a file sums the normalised entropies
+// of all periods weighted by the share of changes to the file.
+func AnalyseChangeEntropy(history []*HistoryCommit, headFiles map[string]bool, opts util.Options) map[string]interface{}{
+    util.PrintDebug("analysing change entropy", opts)
+    periodLength := time.Duration(opts.EntropyPeriodDays) * 24 * time.Hour
+    burstGap := time.Duration(opts.EntropyBurstHours) * time.Hour
+
+    // count the source files at the analysed head
+    fileCount := 0
+    for file := range(headFiles){
+        if IsSourceFile(file, opts){
+            fileCount += 1
+        }
+    }
+
+    var periods []EntropyPeriod
+    complexity := make(map[string]float64)
+    counts := make(map[string]int)
+    var current *EntropyPeriod
+
+    // close the current period
+    closePeriod := func(){
+        if current == nil || current.Changes == 0{
+            return
+        }
+
+        // calculate entropy of the period
+        probabilities := make(map[string]float64)
+        for file, c := range(counts){
+            p := float64(c) / float64(current.Changes)
+            probabilities[file] = p
+            current.Entropy -= p * math.Log2(p)
+        }
+
+        if fileCount > 1{
+            current.NormalisedEntropy = current.Entropy / math.Log2(float64(fileCount))
+        }
+
+        // add to the history complexity of the changed files
+        for file, p := range(probabilities){
+            complexity[file] += p * current.NormalisedEntropy
+        }
+
+        periods = append(periods, *current)
+        counts = make(map[string]int)
+    }
+
+    var last time.Time
+    for _, commit := range(history){
+        if len(commit.Parents) > 1{
+            continue
+        }
+
+        // start a new period if needed
+        newPeriod := current == nil
+        if !newPeriod && burstGap > 0{
+            newPeriod = commit.When.Sub(last) >= burstGap
+        } else if !newPeriod{
+            newPeriod = commit.When.Sub(current.Start) >= periodLength
+        }
+
+        if newPeriod{
+            closePeriod()
+            current = &EntropyPeriod{Start: commit.When}
+        }
+        current.End = commit.When
+        last = commit.When
+
+        // count changes
+        for _, change := range(commit.Changes){
+            if !IsSourceFile(change.Path, opts){
+                continue
+            }
+
+            counts[change.Path] += 1
+            current.Changes += 1
+        }
+    }
+    closePeriod()
+
+    // aggregate entropies
+    entropies := make([]float64, 0, len(periods))
+    maximum := 0.0
+    for _, period := range(periods){
+        entropies = append(entropies, period.NormalisedEntropy)
+        if period.NormalisedEntropy > maximum{
+            maximum = period.NormalisedEntropy
+        }
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["ChangeEntropyPeriods"] = periods
+    result["AvgChangeEntropy"] = util.Mean(entropies)
+    result["MaxChangeEntropy"] = maximum
+    result["FileHistoryComplexity"] = complexity
+
+    return result
+}
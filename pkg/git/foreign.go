@@ -0,0 +1,297 @@
+package git
+
+import (
+    "io"
+    "path"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/filter"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// kinds of foreign code
+const (
+    ForeignGenerated = "generated"
+    ForeignVendored = "vendored"
+)
+
+// number of bytes at the start of files searched for generator markers
+const generatorHeaderSize = 2048
+
+// matches comments left by code generators, generic phrases
+// only at the start of a comment line like "// Generated by"
+// or " * This file was auto-generated from"
+var generatorRegexp = regexp.MustCompile(`(?im)do not edit|@generated|` +
+    `^[ \t]*(//+|/\*+|\*|#)?[ \t]*(this (file|code|header|source file) (is|was|has been) )?(automatically generated|auto-?generated|generated (automatically )?(by|from))\b|` +
+    `a bison parser|lexical scanner generated by flex|meta object code from reading|created by: the qt meta object compiler|created by: qt user interface compiler|this file was generated by swig`)
+
+// matches names of files created by common code generators
+var generatedNameRegexp = regexp.MustCompile(`(\.pb\.(h|cc)|\.grpc\.pb\.(h|cc)|\.tab\.(c|h|cc|hh|cpp|hpp)|_generated\.(h|hpp|c|cpp)|^lex\.yy\.(c|cc)|^(moc|qrc)_.+\.cpp|^ui_.+\.h)$`)
+
+// names of directories commonly containing third-party code
+var vendorDirNames = []string{
+    "third_party", "third-party", "thirdparty", "3rdparty", "3rd_party",
+    "vendor", "vendored", "external", "extern", "deps",
+}
+
+// names of directories of commonly bundled libraries
+var libraryDirNames = []string{
+    "googletest", "gtest", "gmock", "catch2", "boost", "eigen", "zlib",
+    "libpng", "nlohmann", "fmt", "spdlog", "sqlite", "sqlite3", "lua",
+    "rapidjson", "pugixml", "tinyxml2", "abseil-cpp", "benchmark",
+    "protobuf", "yaml-cpp", "imgui", "glfw", "stb", "doctest", "pybind11",
+}
+
+// names of license files marking copied libraries
+var licenseNames = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "COPYING", "COPYING.txt", "LICENCE"}
+
+// matches the directory of commits created by git subtree
+var subtreeRegexp = regexp.MustCompile(`(?m)^git-subtree-dir:\s*(\S+)`)
+
+// struct describing a file or directory detected as foreign code
+type ForeignCode struct{
+    Path string
+    Kind string
+    Reason string
+}
+
+// Detect generated files and vendored libraries among the
+// files at the analysed head of the repository in repoPath.
+// Generated files are recognised by generator comments in their
+// header, by the names of common generator outputs and by having
+// at least opts.GeneratedMinLines lines added in a single commit
+// without later changes. Vendored libraries are recognised by
+// common third-party directory names, license files below the
+// root and git subtree merges. Directories named like bundled
+// libraries only count if they are detected by one of these,
+// so the repository's own library directories are kept.
+// Outputs a map with the following fields:
+//   GeneratedFileCount int
+//   VendoredFileCount  int
+//   VendoredDirs       []ForeignCode
+//   GeneratedFiles     []ForeignCode
+// and the exclude patterns matching the detected code.
+func AnalyseForeignCode(repoPath string, opts util.Options) (map[string]interface{}, []string, error){
+    util.PrintDebug("detecting generated and vendored code", opts)
+    repo, err := git.PlainOpen(repoPath)
+
+    if err != nil{
+        return nil, nil, err
+    }
+
+    headCommit, err := ResolveHead(repo, opts)
+
+    if err != nil{
+        return nil, nil, err
+    }
+
+    // load history for single commit files and subtrees
+    history, err := LoadHistory(repo, opts)
+
+    if err != nil{
+        return nil, nil, err
+    }
+
+    changeCounts := make(map[string]int)
+    additions := make(map[string]int)
+    subtrees := make(map[string]bool)
+    for _, commit := range(history){
+        for _, change := range(commit.Changes){
+            changeCounts[change.Path] += 1
+            additions[change.Path] += change.Additions
+        }
+
+        for _, match := range(subtreeRegexp.FindAllStringSubmatch(commit.Message, -1)){
+            subtrees[strings.Trim(match[1], "/")] = true
+        }
+    }
+
+    // collect files and directories with license files,
+    // license files below the root mark copied libraries
+    var files []*object.File
+    licensed := make(map[string]bool)
+    fileIter, err := headCommit.Files()
+
+    if err != nil{
+        return nil, nil, err
+    }
+
+    err = fileIter.ForEach(func(file *object.File) error{
+        files = append(files, file)
+        if util.ContainsString(licenseNames, path.Base(file.Name)) && path.Dir(file.Name) != "."{
+            licensed[path.Dir(file.Name)] = true
+        }
+
+        return nil
+    })
+
+    if err != nil{
+        return nil, nil, err
+    }
+
+    // collect vendored directories
+    vendored := make(map[string]string)
+    for _, file := range(files){
+        // test the directories of the file
+        dirs := strings.Split(path.Dir(file.Name), "/")
+        for i, dir := range(dirs){
+            if dir == "."{
+                break
+            }
+
+            prefix := strings.Join(dirs[:i + 1], "/")
+            if _, ok := vendored[prefix]; ok{
+                break
+            }
+
+            switch{
+            case subtrees[prefix]:
+                vendored[prefix] = "git subtree"
+            case util.ContainsString(vendorDirNames, strings.ToLower(dir)):
+                vendored[prefix] = "third-party directory name"
+            case util.ContainsString(libraryDirNames, strings.ToLower(dir)) && isBundledLibrary(dirs[:i + 1], licensed):
+                vendored[prefix] = "bundled library directory"
+            }
+
+            if _, ok := vendored[prefix]; ok{
+                break
+            }
+        }
+    }
+
+    for dir := range(licensed){
+        if _, ok := vendored[dir]; !ok{
+            vendored[dir] = "license file"
+        }
+    }
+
+    // remove vendored directories nested in other ones
+    var dirs []ForeignCode
+    for dir, reason := range(vendored){
+        nested := false
+        for other := range(vendored){
+            if strings.HasPrefix(dir, other + "/"){
+                nested = true
+                break
+            }
+        }
+
+        if !nested{
+            dirs = append(dirs, ForeignCode{Path: dir, Kind: ForeignVendored, Reason: reason})
+        }
+    }
+
+    sort.Slice(dirs, func(i, j int) bool{
+        return dirs[i].Path < dirs[j].Path
+    })
+
+    // classify source files
+    var generated []ForeignCode
+    vendoredFiles := 0
+    for _, file := range(files){
+        if !IsSourceFile(file.Name, opts){
+            continue
+        }
+
+        if inForeignDir(file.Name, dirs){
+            vendoredFiles += 1
+            continue
+        }
+
+        reason, err := generatedReason(file, changeCounts[file.Name], additions[file.Name], opts)
+
+        if err != nil{
+            return nil, nil, err
+        }
+
+        if reason != ""{
+            generated = append(generated, ForeignCode{Path: file.Name, Kind: ForeignGenerated, Reason: reason})
+        }
+    }
+
+    // create exclude patterns
+    var patterns []string
+    for _, dir := range(dirs){
+        patterns = append(patterns, "/" + filter.Escape(dir.Path) + "/")
+    }
+
+    for _, file := range(generated){
+        patterns = append(patterns, "/" + filter.Escape(file.Path))
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["GeneratedFileCount"] = len(generated)
+    result["VendoredFileCount"] = vendoredFiles
+    result["VendoredDirs"] = dirs
+    result["GeneratedFiles"] = generated
+
+    return result, patterns, nil
+}
+
+// Return why file is considered generated, or an empty string.
+// changes and additions are the number of commits changing
+// the file and the number of lines they added.
+func generatedReason(file *object.File, changes, additions int, opts util.Options) (string, error){
+    if generatedNameRegexp.MatchString(path.Base(file.Name)){
+        return "generator output name", nil
+    }
+
+    // search the file header for generator comments
+    if file.Size > 0{
+        reader, err := file.Reader()
+
+        if err != nil{
+            return "", err
+        }
+
+        header := make([]byte, generatorHeaderSize)
+        n, err := io.ReadFull(reader, header)
+        reader.Close()
+
+        if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF{
+            return "", err
+        }
+
+        if generatorRegexp.Match(header[:n]){
+            return "generator comment", nil
+        }
+    }
+
+    if opts.GeneratedMinLines > 0 && changes == 1 && additions >= opts.GeneratedMinLines{
+        return "large single commit file", nil
+    }
+
+    return "", nil
+}
+
+// Test if the directory given by its path elements dirs, named
+// like a commonly bundled library, is a copy of the library.
+// The repository's own top-level and include/<name> directories
+// are never copies, since libraries use these for their own code.
+// Other directories are copies if they contain a license file,
+// directories in third-party directories and git subtrees are
+// detected before.
+func isBundledLibrary(dirs []string, licensed map[string]bool) bool{
+    if len(dirs) == 1 || (len(dirs) == 2 && dirs[0] == "include"){
+        return false
+    }
+
+    return licensed[strings.Join(dirs, "/")]
+}
+
+// Test if the file given by name lies in one of the directories.
+func inForeignDir(name string, dirs []ForeignCode) bool{
+    for _, dir := range(dirs){
+        if strings.HasPrefix(name, dir.Path + "/"){
+            return true
+        }
+    }
+
+    return false
+}
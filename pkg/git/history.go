@@ -0,0 +1,230 @@
+package git
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "io"
+    "sort"
+    "strings"
+    "time"
+    "unicode"
+
+    "github.com/j-bhm/CppGitMining/pkg/filter"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// struct describing the change of a single file in a commit
+type FileChange struct{
+    Path string       // path after the change, or before for deletions
+    OldPath string    // path before the change, empty for added files
+    Deleted bool      // the file was deleted
+    Additions int     // number of added lines
+    Deletions int     // number of deleted lines
+    Whitespace bool   // only whitespace was changed
+}
+
+// struct describing a commit of the analysed history
+type HistoryCommit struct{
+    Hash plumbing.Hash
+    Author string
+    When time.Time
+    Parents []plumbing.Hash
+    Message string
+    Changes []FileChange // changes relative to the first parent, nil for merges
+    PatchId string       // whitespace-insensitive hash of the changes, empty for merges
+}
+
+// Load the history of the git repository repo
+// selected by the traversal options, ordered by
+// author date with the oldest commit first.
+// File changes are computed for non-merge commits,
+// leaving out files excluded by the path filters.
+func LoadHistory(repo *git.Repository, opts util.Options) ([]*HistoryCommit, error){
+    util.PrintDebug("loading commit history", opts)
+    var history []*HistoryCommit
+    err := ForEachCommit(repo, opts, func(commit *object.Commit) error{
+        entry := &HistoryCommit{
+            Hash: commit.Hash,
+            Author: commit.Author.Name,
+            When: commit.Author.When,
+            Parents: commit.ParentHashes,
+            Message: commit.Message,
+        }
+
+        // compute file changes of non-merge commits
+        if commit.NumParents() <= 1{
+            changes, patchId, err := CommitChanges(commit)
+
+            if err != nil{
+                return err
+            }
+
+            entry.Changes = changes[:0]
+            entry.PatchId = patchId
+            for _, change := range(changes){
+                if filter.Included(change.Path, opts){
+                    entry.Changes = append(entry.Changes, change)
+                }
+            }
+        }
+
+        history = append(history, entry)
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    // order history by date
+    sort.SliceStable(history, func(i, j int) bool{
+        return history[i].When.Before(history[j].When)
+    })
+
+    return history, nil
+}
+
+// Compute the file changes of commit relative to its first parent
+// and their patch id. Like git patch-id, the patch id ignores
+// whitespace, line numbers and context, so cherry-picks of a
+// commit share its patch id. Commits without changes have no patch id.
+func CommitChanges(commit *object.Commit) ([]FileChange, string, error){
+    patch, err := commitPatch(commit)
+
+    if err != nil{
+        return nil, "", err
+    }
+
+    var changes []FileChange
+    patchHash := sha1.New()
+    for _, filePatch := range(patch.FilePatches()){
+        change := FileChange{Path: filePatchPath(filePatch)}
+        patchHash.Write([]byte(change.Path + "\n"))
+        from, to := filePatch.Files()
+        if from != nil{
+            change.OldPath = from.Path()
+        }
+        change.Deleted = to == nil
+
+        // count changed lines and compare the changed text
+        var added, deleted strings.Builder
+        for _, chunk := range(filePatch.Chunks()){
+            lines := strings.Count(chunk.Content(), "\n")
+            if !strings.HasSuffix(chunk.Content(), "\n"){
+                lines += 1
+            }
+
+            switch chunk.Type(){
+            case fdiff.Add:
+                change.Additions += lines
+                added.WriteString(chunk.Content())
+                writePatchLines(patchHash, "+", chunk.Content())
+            case fdiff.Delete:
+                change.Deletions += lines
+                deleted.WriteString(chunk.Content())
+                writePatchLines(patchHash, "-", chunk.Content())
+            }
+        }
+
+        // test for whitespace only changes of modified files
+        if from != nil && to != nil && !filePatch.IsBinary() && change.Additions + change.Deletions > 0{
+            change.Whitespace = stripWhitespace(added.String()) == stripWhitespace(deleted.String())
+        }
+
+        changes = append(changes, change)
+    }
+
+    if len(changes) == 0{
+        return changes, "", nil
+    }
+
+    return changes, hex.EncodeToString(patchHash.Sum(nil)), nil
+}
+
+// Write the lines of content with the given prefix
+// and without whitespace to w.
+func writePatchLines(w io.Writer, prefix, content string){
+    for _, line := range(strings.Split(strings.TrimSuffix(content, "\n"), "\n")){
+        io.WriteString(w, prefix + stripWhitespace(line) + "\n")
+    }
+}
+
+// Return the files in the tree of the analysed
+// head of repo given by ResolveHead.
+func HeadFiles(repo *git.Repository, opts util.Options) (map[string]bool, error){
+    headCommit, err := ResolveHead(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    fileIter, err := headCommit.Files()
+
+    if err != nil{
+        return nil, err
+    }
+
+    files := make(map[string]bool)
+    err = fileIter.ForEach(func(file *object.File) error{
+        files[file.Name] = true
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    return files, nil
+}
+
+// Compute the patch of commit relative to its first parent.
+func commitPatch(commit *object.Commit) (*object.Patch, error){
+    tree, err := commit.Tree()
+
+    if err != nil{
+        return nil, err
+    }
+
+    parentTree := &object.Tree{}
+    if commit.NumParents() != 0{
+        parent, err := commit.Parent(0)
+
+        if err != nil{
+            return nil, err
+        }
+
+        parentTree, err = parent.Tree()
+
+        if err != nil{
+            return nil, err
+        }
+    }
+
+    return parentTree.Patch(tree)
+}
+
+// Return the path of the file changed by filePatch.
+func filePatchPath(filePatch fdiff.FilePatch) string{
+    from, to := filePatch.Files()
+    if to != nil{
+        return to.Path()
+    }
+
+    return from.Path()
+}
+
+// Remove all whitespace from s.
+func stripWhitespace(s string) string{
+    return strings.Map(func(r rune) rune{
+        if unicode.IsSpace(r){
+            return -1
+        }
+
+        return r
+    }, s)
+}
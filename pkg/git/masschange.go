@@ -0,0 +1,187 @@
+package git
+
+import (
+    "bufio"
+    "math"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// file listing revisions ignored by git blame
+const BlameIgnoreRevsFile = ".git-blame-ignore-revs"
+
+// reasons for ignoring a commit
+const (
+    IgnoreBlameRevs = "blame-ignore-revs"  // listed in .git-blame-ignore-revs
+    IgnoreList = "ignore-list"             // listed in opts.IgnoreRevsFile
+    IgnoreFileCount = "file-count"         // changes at least opts.MassChangeFiles files
+    IgnorePercentile = "percentile"        // changes more files than opts.MassChangePercentile
+)
+
+// struct describing a mass-change commit left out of
+// the churn and change coupling analyses
+type IgnoredCommit struct{
+    Hash string
+    Files int      // number of changed files
+    Reason string
+}
+
+// Test if opts select mass-change commits to be ignored.
+func IsIgnoringMassChanges(opts util.Options) bool{
+    return opts.IgnoreRevsFile != "" || opts.UseBlameIgnoreRevs || opts.MassChangeFiles > 0 || opts.MassChangePercentile > 0
+}
+
+// Read the commit hashes listed in the file at path.
+// Empty lines and comments starting with '#' are skipped.
+func LoadIgnoreRevs(path string) ([]string, error){
+    file, err := os.Open(path)
+
+    if err != nil{
+        return nil, err
+    }
+    defer file.Close()
+
+    var revs []string
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan(){
+        line := scanner.Text()
+        if i := strings.IndexByte(line, '#'); i >= 0{
+            line = line[:i]
+        }
+
+        line = strings.TrimSpace(line)
+        if line != ""{
+            revs = append(revs, strings.ToLower(line))
+        }
+    }
+
+    return revs, scanner.Err()
+}
+
+// Find the mass-change commits of history selected by opts
+// in the repository in path. Commits can be listed in the
+// .git-blame-ignore-revs of the repository, in a user supplied
+// file or be detected by the number of changed files.
+// Merge commits are never ignored.
+func FindMassChanges(path string, history []*HistoryCommit, opts util.Options) ([]IgnoredCommit, error){
+    // load ignore lists
+    lists := make(map[string][]string)
+    if opts.UseBlameIgnoreRevs{
+        revs, err := LoadIgnoreRevs(filepath.Join(path, BlameIgnoreRevsFile))
+
+        if err != nil && !os.IsNotExist(err){
+            return nil, err
+        }
+
+        lists[IgnoreBlameRevs] = revs
+    }
+
+    if opts.IgnoreRevsFile != ""{
+        revs, err := LoadIgnoreRevs(opts.IgnoreRevsFile)
+
+        if err != nil{
+            return nil, err
+        }
+
+        lists[IgnoreList] = revs
+    }
+
+    // calculate file count threshold of the percentile
+    threshold := math.MaxInt
+    if opts.MassChangeFiles > 0{
+        threshold = opts.MassChangeFiles
+    }
+
+    percentileLimit := math.MaxInt
+    if opts.MassChangePercentile > 0{
+        var counts []int
+        for _, commit := range(history){
+            if len(commit.Parents) <= 1{
+                counts = append(counts, len(commit.Changes))
+            }
+        }
+
+        if len(counts) > 0{
+            sort.Ints(counts)
+            rank := int(math.Ceil(opts.MassChangePercentile / 100 * float64(len(counts)))) - 1
+            if rank < 0{
+                rank = 0
+            } else if rank >= len(counts){
+                rank = len(counts) - 1
+            }
+
+            percentileLimit = counts[rank]
+        }
+    }
+
+    // select commits
+    var ignored []IgnoredCommit
+    for _, commit := range(history){
+        if len(commit.Parents) > 1{
+            continue
+        }
+
+        hash := commit.Hash.String()
+        files := len(commit.Changes)
+        reason := ""
+        switch{
+        case hasPrefix(hash, lists[IgnoreBlameRevs]):
+            reason = IgnoreBlameRevs
+        case hasPrefix(hash, lists[IgnoreList]):
+            reason = IgnoreList
+        case files >= threshold:
+            reason = IgnoreFileCount
+        case files > percentileLimit:
+            reason = IgnorePercentile
+        }
+
+        if reason != ""{
+            ignored = append(ignored, IgnoredCommit{Hash: hash, Files: files, Reason: reason})
+        }
+    }
+
+    return ignored, nil
+}
+
+// Remove the mass-change commits selected by opts from history.
+// Returns the remaining history and the removed commits.
+func RemoveMassChanges(path string, history []*HistoryCommit, opts util.Options) ([]*HistoryCommit, []IgnoredCommit, error){
+    if !IsIgnoringMassChanges(opts){
+        return history, nil, nil
+    }
+
+    ignored, err := FindMassChanges(path, history, opts)
+
+    if err != nil{
+        return nil, nil, err
+    }
+
+    skip := make(map[string]bool)
+    for _, commit := range(ignored){
+        skip[commit.Hash] = true
+    }
+
+    remaining := make([]*HistoryCommit, 0, len(history) - len(ignored))
+    for _, commit := range(history){
+        if !skip[commit.Hash.String()]{
+            remaining = append(remaining, commit)
+        }
+    }
+
+    return remaining, ignored, nil
+}
+
+// Test if hash starts with one of the prefixes.
+func hasPrefix(hash string, prefixes []string) bool{
+    for _, prefix := range(prefixes){
+        if strings.HasPrefix(hash, prefix){
+            return true
+        }
+    }
+
+    return false
+}
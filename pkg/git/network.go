@@ -0,0 +1,177 @@
+package git
+
+import (
+    "sort"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/allen"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// unordered pair of developers, with A < B
+type DevPair struct{
+    A string
+    B string
+}
+
+// Create the developer pair of a and b.
+func NewDevPair(a, b string) DevPair{
+    if b < a{
+        a, b = b, a
+    }
+
+    return DevPair{A: a, B: b}
+}
+
+// Count the commits of every author for every source file in history.
+// Returns a map from files to authors to their number of commits.
+func FileAuthors(history []*HistoryCommit, opts util.Options) map[string]map[string]int{
+    result := make(map[string]map[string]int)
+    for _, commit := range(history){
+        for _, change := range(commit.Changes){
+            if !IsSourceFile(change.Path, opts){
+                continue
+            }
+
+            authors := result[change.Path]
+            if authors == nil{
+                authors = make(map[string]int)
+                result[change.Path] = authors
+            }
+            authors[commit.Author] += 1
+        }
+    }
+
+    return result
+}
+
+// Collect the collaborating developers in history.
+// Two developers collaborate if they modify the same
+// source file within window of each other.
+// Returns all developers that modified a source file
+// and a map from collaborating pairs to their number
+// of shared files.
+func Collaborations(history []*HistoryCommit, window time.Duration, opts util.Options) ([]string, map[DevPair]int){
+    // struct describing a modification of a file
+    type modification struct{
+        author string
+        when time.Time
+    }
+
+    // collect modifications per file in order of time
+    developers := make(map[string]bool)
+    modifications := make(map[string][]modification)
+    for _, commit := range(history){
+        for _, change := range(commit.Changes){
+            if !IsSourceFile(change.Path, opts){
+                continue
+            }
+
+            developers[commit.Author] = true
+            modifications[change.Path] = append(modifications[change.Path], modification{commit.Author, commit.When})
+        }
+    }
+
+    // find pairs modifying the same file within the window
+    shared := make(map[DevPair]map[string]bool)
+    for file, mods := range(modifications){
+        for i := range(mods){
+            for j := i - 1; j >= 0 && mods[i].when.Sub(mods[j].when) <= window; j--{
+                if mods[i].author == mods[j].author{
+                    continue
+                }
+
+                pair := NewDevPair(mods[i].author, mods[j].author)
+                if shared[pair] == nil{
+                    shared[pair] = make(map[string]bool)
+                }
+                shared[pair][file] = true
+            }
+        }
+    }
+
+    // count shared files
+    pairs := make(map[DevPair]int)
+    for pair, files := range(shared){
+        pairs[pair] = len(files)
+    }
+
+    names := make([]string, 0, len(developers))
+    for name := range(developers){
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    return names, pairs
+}
+
+// Analyse the developer collaboration network of history.
+// Developers are linked if they modify the same source file
+// within opts.DevNetworkWindow days, weighted by their shared files.
+// Outputs a map with the following fields:
+//   DevNetworkNodeCount      int
+//   DevNetworkEdgeCount      int
+//   DevNetworkSize           float64
+//   DevNetworkComplexity     float64
+//   DevNetworkDensity        float64
+//   DevNetworkCentralisation float64
+// DevNetworkCentralisation is the degree centralisation after Freeman.
+func AnalyseDevNetwork(history []*HistoryCommit, opts util.Options) map[string]interface{}{
+    util.PrintDebug("analysing developer network", opts)
+    window := time.Duration(opts.DevNetworkWindow) * 24 * time.Hour
+    developers, pairs := Collaborations(history, window, opts)
+
+    // create graph nodes
+    nodes := make(map[string]*allen.GraphNode)
+    graph := make(allen.Graph, 0, len(developers))
+    for _, name := range(developers){
+        node := new(allen.GraphNode)
+        nodes[name] = node
+        graph = append(graph, node)
+    }
+
+    // add an edge per collaborating pair
+    degrees := make(map[string]int)
+    for pair, weight := range(pairs){
+        startNode := nodes[pair.A]
+        endNode := nodes[pair.B]
+        startNode.OutEdges = append(startNode.OutEdges, allen.GraphEdge{Node: endNode, Weight: float64(weight)})
+        endNode.InEdges = append(endNode.InEdges, allen.GraphEdge{Node: startNode, Weight: float64(weight)})
+        degrees[pair.A] += 1
+        degrees[pair.B] += 1
+    }
+
+    // calculate density and degree centralisation
+    n := float64(len(developers))
+    density := 0.0
+    centralisation := 0.0
+    if n > 1{
+        density = 2 * float64(len(pairs)) / (n * (n - 1))
+    }
+
+    if n > 2{
+        maxDegree := 0
+        for _, d := range(degrees){
+            if d > maxDegree{
+                maxDegree = d
+            }
+        }
+
+        sum := 0.0
+        for _, name := range(developers){
+            sum += float64(maxDegree - degrees[name])
+        }
+        centralisation = sum / ((n - 1) * (n - 2))
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["DevNetworkNodeCount"] = len(developers)
+    result["DevNetworkEdgeCount"] = len(pairs)
+    result["DevNetworkSize"] = allen.EstGraphSize(graph)
+    result["DevNetworkComplexity"] = allen.EstGraphComplexity(graph)
+    result["DevNetworkDensity"] = density
+    result["DevNetworkCentralisation"] = centralisation
+
+    return result
+}
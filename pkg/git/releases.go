@@ -0,0 +1,252 @@
+package git
+
+import (
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+)
+
+// struct describing a release tag
+type Release struct{
+    Tag string
+    Version []int
+    Prerelease bool
+    Hash string       // hash of the tagged commit
+    When time.Time
+    Commits int       // commits first reachable from this release
+    Contributors int  // authors of these commits
+}
+
+// matches version tags like "v1.2.3", "1.2-rc1", "release-1.2" or "REL_15_2"
+var versionRegexp = regexp.MustCompile(`(?i)^(?:[a-z][a-z0-9]*[-_/])*v?([0-9]+(?:[._][0-9]+)*)(?:[-+~.]?([0-9a-z.-]+))?$`)
+
+// matches suffixes of pre-release versions
+var prereleaseRegexp = regexp.MustCompile(`(?i)^(alpha|beta|rc|pre|dev|snapshot|a|b)`)
+
+// Parse the version number of a tag name.
+// Returns the version components, whether the
+// version is a pre-release and false if the
+// tag is not a version.
+func ParseVersion(tag string) ([]int, bool, bool){
+    match := versionRegexp.FindStringSubmatch(tag)
+    if match == nil{
+        return nil, false, false
+    }
+
+    // split version components
+    parts := strings.FieldsFunc(match[1], func(r rune) bool{
+        return r == '.' || r == '_'
+    })
+
+    version := make([]int, len(parts))
+    for i, part := range(parts){
+        v, err := strconv.Atoi(part)
+
+        if err != nil{
+            return nil, false, false
+        }
+
+        version[i] = v
+    }
+
+    return version, prereleaseRegexp.MatchString(match[2]), true
+}
+
+// Compare the versions a and b.
+// Returns a negative number if a < b,
+// zero if a == b and a positive number otherwise.
+func CompareVersions(a, b []int) int{
+    for i := 0; i < len(a) || i < len(b); i++{
+        var va, vb int
+        if i < len(a){
+            va = a[i]
+        }
+
+        if i < len(b){
+            vb = b[i]
+        }
+
+        if va != vb{
+            return va - vb
+        }
+    }
+
+    return 0
+}
+
+// Collect the release tags of repo ordered by date.
+// Tags without a version number are ignored.
+func LoadReleases(repo *git.Repository) ([]*Release, error){
+    tagIter, err := repo.Tags()
+
+    if err != nil{
+        return nil, err
+    }
+
+    var releases []*Release
+    err = tagIter.ForEach(func(ref *plumbing.Reference) error{
+        name := ref.Name().Short()
+        version, prerelease, ok := ParseVersion(name)
+        if !ok{
+            return nil
+        }
+
+        hash, ok := peelToCommit(repo, ref.Hash())
+        if !ok{
+            return nil
+        }
+
+        commit, err := repo.CommitObject(hash)
+
+        if err != nil{
+            return err
+        }
+
+        releases = append(releases, &Release{
+            Tag: name,
+            Version: version,
+            Prerelease: prerelease,
+            Hash: hash.String(),
+            When: commit.Committer.When,
+        })
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    // order releases by date and version
+    sort.SliceStable(releases, func(i, j int) bool{
+        if !releases[i].When.Equal(releases[j].When){
+            return releases[i].When.Before(releases[j].When)
+        }
+
+        return CompareVersions(releases[i].Version, releases[j].Version) < 0
+    })
+
+    return releases, nil
+}
+
+// Count the commits and contributors of releases.
+// Commits are attributed to the first release
+// from which they are reachable.
+func countReleaseCommits(repo *git.Repository, releases []*Release) error{
+    // attribute commits to the first release reaching them
+    seen := make(map[plumbing.Hash]bool)
+    for _, release := range(releases){
+        authors := make(map[string]bool)
+        stack := []plumbing.Hash{plumbing.NewHash(release.Hash)}
+        for len(stack) > 0{
+            hash := stack[len(stack) - 1]
+            stack = stack[:len(stack) - 1]
+
+            if seen[hash]{
+                continue
+            }
+            seen[hash] = true
+
+            commit, err := repo.CommitObject(hash)
+
+            if err == plumbing.ErrObjectNotFound{
+                continue
+            }
+
+            if err != nil{
+                return err
+            }
+
+            if commit.NumParents() <= 1{
+                release.Commits += 1
+                authors[commit.Author.Name] = true
+            }
+
+            stack = append(stack, commit.ParentHashes...)
+        }
+
+        release.Contributors = len(authors)
+    }
+
+    return nil
+}
+
+// Analyse the release tags of repo.
+// Pre-releases are counted but left out of all other fields.
+// Outputs a map with the following fields:
+//   ReleaseCount           int
+//   PrereleaseCount        int
+//   ReleasesPerYear        float64
+//   AvgReleaseInterval     float64
+//   MedianReleaseInterval  float64
+//   AvgReleaseCommits      float64
+//   AvgReleaseContributors float64
+//   Releases               []Release
+// Intervals are given in hours.
+func AnalyseReleases(repo *git.Repository, opts util.Options) (map[string]interface{}, []*Release, error){
+    util.PrintDebug("analysing releases", opts)
+    all, err := LoadReleases(repo)
+
+    if err != nil{
+        return nil, nil, err
+    }
+
+    // separate pre-releases
+    var releases []*Release
+    prereleases := 0
+    for _, release := range(all){
+        if release.Prerelease{
+            prereleases += 1
+        } else{
+            releases = append(releases, release)
+        }
+    }
+
+    err = countReleaseCommits(repo, releases)
+
+    if err != nil{
+        return nil, nil, err
+    }
+
+    // calculate intervals and averages
+    var intervals []float64
+    var commits []float64
+    var contributors []float64
+    list := make([]Release, 0, len(releases))
+    for i, release := range(releases){
+        if i > 0{
+            intervals = append(intervals, release.When.Sub(releases[i - 1].When).Hours())
+        }
+
+        commits = append(commits, float64(release.Commits))
+        contributors = append(contributors, float64(release.Contributors))
+        list = append(list, *release)
+    }
+
+    perYear := 0.0
+    if len(releases) > 1{
+        span := releases[len(releases) - 1].When.Sub(releases[0].When).Hours() / (365.25 * 24)
+        if span > 0{
+            perYear = float64(len(releases) - 1) / span
+        }
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["ReleaseCount"] = len(releases)
+    result["PrereleaseCount"] = prereleases
+    result["ReleasesPerYear"] = perYear
+    result["AvgReleaseInterval"] = util.Mean(intervals)
+    result["MedianReleaseInterval"] = util.Median(intervals)
+    result["AvgReleaseCommits"] = util.Mean(commits)
+    result["AvgReleaseContributors"] = util.Mean(contributors)
+    result["Releases"] = list
+
+    return result, releases, nil
+}
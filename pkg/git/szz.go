@@ -0,0 +1,264 @@
+package git
+
+import (
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+)
+
+// struct describing a bug-inducing commit found for a fix commit
+type SzzPair struct{
+    Fixing string    // hash of the fix commit
+    Inducing string  // hash of the bug-inducing commit
+    File string      // file in which the bug was induced
+}
+
+// Identify bug-inducing commits with the SZZ algorithm
+// in the repository in path. For every corrective commit of
+// history the deleted and modified lines of source files
+// are blamed in the parent revision. Blank, comment-only and
+// whitespace-only changed lines are ignored.
+// Outputs a map with the following fields:
+//   SzzFixCommitCount      int
+//   SzzInducingCommitCount int
+//   SzzPairs               []SzzPair
+//   SzzFileDefectCounts    map[string]int
+// SzzFileDefectCounts counts the bug-inducing commits per file.
+func AnalyseSzz(path string, history []*HistoryCommit, opts util.Options) (map[string]interface{}, error){
+    // find fix commits
+    util.PrintDebug("running szz analysis", opts)
+    rules, err := classRules(opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    var fixes []*HistoryCommit
+    for _, commit := range(history){
+        if len(commit.Parents) == 1 && ClassifyCommit(commit, rules) == ClassCorrective{
+            fixes = append(fixes, commit)
+        }
+    }
+
+    // blame the changed lines of every fix in parallel
+    var mutex sync.Mutex
+    var pairs []SzzPair
+    err = forEachParallel(path, len(fixes), opts, func(repo *git.Repository, i int){
+        fixPairs, err := szzFix(repo, fixes[i], opts)
+
+        if err != nil{
+            util.PrintDebug("szz failed for " + fixes[i].Hash.String() + ": " + err.Error(), opts)
+            return
+        }
+
+        mutex.Lock()
+        pairs = append(pairs, fixPairs...)
+        mutex.Unlock()
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    // order pairs for a stable output
+    sort.Slice(pairs, func(i, j int) bool{
+        if pairs[i].Fixing != pairs[j].Fixing{
+            return pairs[i].Fixing < pairs[j].Fixing
+        }
+
+        if pairs[i].File != pairs[j].File{
+            return pairs[i].File < pairs[j].File
+        }
+
+        return pairs[i].Inducing < pairs[j].Inducing
+    })
+
+    // count bug-inducing commits
+    inducing := make(map[string]bool)
+    fileInducing := make(map[string]map[string]bool)
+    for _, pair := range(pairs){
+        inducing[pair.Inducing] = true
+        if fileInducing[pair.File] == nil{
+            fileInducing[pair.File] = make(map[string]bool)
+        }
+        fileInducing[pair.File][pair.Inducing] = true
+    }
+
+    fileDefects := make(map[string]int)
+    for file, commits := range(fileInducing){
+        fileDefects[file] = len(commits)
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["SzzFixCommitCount"] = len(fixes)
+    result["SzzInducingCommitCount"] = len(inducing)
+    result["SzzPairs"] = pairs
+    result["SzzFileDefectCounts"] = fileDefects
+
+    // return result
+    return result, nil
+}
+
+// Find the bug-inducing commits of the fix commit fix in repo.
+func szzFix(repo *git.Repository, fix *HistoryCommit, opts util.Options) ([]SzzPair, error){
+    commit, err := repo.CommitObject(fix.Hash)
+
+    if err != nil{
+        return nil, err
+    }
+
+    parent, err := commit.Parent(0)
+
+    if err != nil{
+        return nil, err
+    }
+
+    patch, err := commitPatch(commit)
+
+    if err != nil{
+        return nil, err
+    }
+
+    var pairs []SzzPair
+    for _, filePatch := range(patch.FilePatches()){
+        // only consider changed or deleted source files
+        from, _ := filePatch.Files()
+        if from == nil || filePatch.IsBinary() || !IsSourceFile(from.Path(), opts){
+            continue
+        }
+
+        lines := szzDeletedLines(filePatch)
+        if len(lines) == 0{
+            continue
+        }
+
+        // skip files exceeding the blame size cap
+        file, err := parent.File(from.Path())
+
+        if err != nil{
+            return nil, err
+        }
+
+        if opts.BlameMaxSize > 0 && file.Size > opts.BlameMaxSize{
+            continue
+        }
+
+        // blame the file in the parent revision
+        blameResult, err := blameFile(repo, parent.Hash, from.Path())
+
+        if err != nil{
+            return nil, err
+        }
+
+        // collect commits that introduced the deleted lines
+        seen := make(map[plumbing.Hash]bool)
+        for _, line := range(lines){
+            if line >= len(blameResult.Lines){
+                continue
+            }
+
+            hash := blameResult.Lines[line].Hash
+            if seen[hash]{
+                continue
+            }
+            seen[hash] = true
+
+            pairs = append(pairs, SzzPair{
+                Fixing: fix.Hash.String(),
+                Inducing: hash.String(),
+                File: from.Path(),
+            })
+        }
+    }
+
+    return pairs, nil
+}
+
+// Return the zero-based numbers of the lines deleted
+// or modified by filePatch in the old file, skipping
+// blank and comment lines as well as lines whose
+// content only changed in whitespace.
+func szzDeletedLines(filePatch fdiff.FilePatch) []int{
+    // collect added lines to detect whitespace changes
+    added := make(map[string]bool)
+    for _, chunk := range(filePatch.Chunks()){
+        if chunk.Type() == fdiff.Add{
+            for _, line := range(splitLines(chunk.Content())){
+                added[stripWhitespace(line)] = true
+            }
+        }
+    }
+
+    // walk the old file
+    var result []int
+    lineNumber := 0
+    inComment := false
+    for _, chunk := range(filePatch.Chunks()){
+        switch chunk.Type(){
+        case fdiff.Equal:
+            for _, line := range(splitLines(chunk.Content())){
+                _, inComment = isCppNoise(line, inComment)
+                lineNumber += 1
+            }
+        case fdiff.Delete:
+            for _, line := range(splitLines(chunk.Content())){
+                var noise bool
+                noise, inComment = isCppNoise(line, inComment)
+                stripped := stripWhitespace(line)
+                if !noise && !added[stripped]{
+                    result = append(result, lineNumber)
+                }
+
+                lineNumber += 1
+            }
+        }
+    }
+
+    return result
+}
+
+// Test if line is blank or only contains a c or cpp comment.
+// inComment tells if the line starts inside a block comment.
+// Returns the result and if the next line starts inside a block comment.
+func isCppNoise(line string, inComment bool) (bool, bool){
+    trimmed := strings.TrimSpace(line)
+    code := false
+    for i := 0; i < len(trimmed); {
+        switch{
+        case inComment:
+            end := strings.Index(trimmed[i:], "*/")
+            if end < 0{
+                i = len(trimmed)
+                continue
+            }
+
+            i += end + 2
+            inComment = false
+        case strings.HasPrefix(trimmed[i:], "//"):
+            i = len(trimmed)
+        case strings.HasPrefix(trimmed[i:], "/*"):
+            inComment = true
+            i += 2
+        default:
+            if trimmed[i] != ' ' && trimmed[i] != '\t'{
+                code = true
+            }
+            i += 1
+        }
+    }
+
+    return !code, inComment
+}
+
+// Split content into lines without line endings.
+func splitLines(content string) []string{
+    content = strings.TrimSuffix(content, "\n")
+    return strings.Split(content, "\n")
+}
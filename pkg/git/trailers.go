@@ -0,0 +1,221 @@
+package git
+
+import (
+    "regexp"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// keys of the parsed commit trailers
+const (
+    TrailerCoAuthoredBy = "Co-authored-by"
+    TrailerSignedOffBy = "Signed-off-by"
+    TrailerReviewedBy = "Reviewed-by"
+    TrailerAckedBy = "Acked-by"
+    TrailerTestedBy = "Tested-by"
+    TrailerReportedBy = "Reported-by"
+)
+
+// all parsed trailer keys
+var trailerKeys = []string{
+    TrailerCoAuthoredBy,
+    TrailerSignedOffBy,
+    TrailerReviewedBy,
+    TrailerAckedBy,
+    TrailerTestedBy,
+    TrailerReportedBy,
+}
+
+// matches a trailer line "<key>: <name> <email>"
+var trailerRegexp = regexp.MustCompile(`^([A-Za-z-]+):\s*([^<]*?)\s*(<([^>]*)>)?\s*$`)
+
+// matches the blank lines separating paragraphs
+var paragraphRegexp = regexp.MustCompile(`\n[ \t]*\n`)
+
+// struct describing a person named in a commit trailer
+type Trailer struct{
+    Key string
+    Name string
+    Email string
+}
+
+// Parse the known trailers of a commit message.
+// Keys are matched case-insensitively and returned
+// in their canonical spelling. Like git interpret-trailers,
+// only the last paragraph of the message is searched.
+func ParseTrailers(message string) []Trailer{
+    var trailers []Trailer
+    for _, line := range(trailerBlock(message)){
+        match := trailerRegexp.FindStringSubmatch(strings.TrimSpace(line))
+        if match == nil{
+            continue
+        }
+
+        for _, key := range(trailerKeys){
+            if strings.EqualFold(match[1], key){
+                trailer := Trailer{Key: key, Name: match[2], Email: match[4]}
+
+                // use the email address if no name is given
+                if trailer.Name == ""{
+                    trailer.Name = trailer.Email
+                }
+
+                if trailer.Name != ""{
+                    trailers = append(trailers, trailer)
+                }
+                break
+            }
+        }
+    }
+
+    return trailers
+}
+
+// Return the lines of the trailer block of a commit message, which
+// is its last paragraph unless that is the subject. The block has to
+// consist of trailer lines and their indented continuations, or contain
+// a known trailer and at least a quarter of trailer lines.
+func trailerBlock(message string) []string{
+    paragraphs := paragraphRegexp.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), -1)
+    if len(paragraphs) < 2{
+        return nil
+    }
+
+    lines := strings.Split(paragraphs[len(paragraphs) - 1], "\n")
+    trailerLines := 0
+    known := false
+    for _, line := range(lines){
+        match := trailerRegexp.FindStringSubmatch(strings.TrimSpace(line))
+        switch{
+        case match != nil && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t"):
+            trailerLines += 1
+            for _, key := range(trailerKeys){
+                if strings.EqualFold(match[1], key){
+                    known = true
+                }
+            }
+        case strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t"):
+            // continuation of the previous trailer
+            trailerLines += 1
+        }
+    }
+
+    if trailerLines == len(lines) || (known && 4 * trailerLines >= len(lines)){
+        return lines
+    }
+
+    return nil
+}
+
+// Analyse the commit trailers of the git repository repo.
+// Outputs a map with the following fields:
+//   TrailerCounts                 map[string]int
+//   CoAuthoredCommitRatio         float64
+//   CoAuthorContributorCount      int
+//   AvgCoAuthorContributorCommits float64
+//   ReviewCoverage                float64
+//   ReviewerCount                 int
+//   ReviewerNetwork               map[string]map[string]int
+// Co-authors are credited like commit authors. ReviewCoverage is
+// the share of non-merge commits with a Reviewed-by or Acked-by trailer
+// and ReviewerNetwork maps authors to their reviewers and the number
+// of reviewed commits.
+func AnalyseTrailers(repo *git.Repository, opts util.Options) (map[string]interface{}, error){
+    util.PrintDebug("analysing commit trailers", opts)
+    commitCount := 0
+    coAuthoredCount := 0
+    reviewedCount := 0
+    counts := make(map[string]int)
+    contributors := make(map[string]int) // maps contributors to their number of commits
+    network := make(map[string]map[string]int)
+    err := ForEachCommit(repo, opts, func(commit *object.Commit) error{
+        // only consider non-merge commits like the contributor metrics
+        if commit.NumParents() > 1{
+            return nil
+        }
+        commitCount += 1
+
+        // credit author and co-authors once per commit
+        credited := map[string]bool{commit.Author.Name: true}
+        coAuthored := false
+        reviewed := false
+        for _, trailer := range(ParseTrailers(commit.Message)){
+            counts[trailer.Key] += 1
+
+            switch trailer.Key{
+            case TrailerCoAuthoredBy:
+                coAuthored = true
+                credited[trailer.Name] = true
+            case TrailerReviewedBy, TrailerAckedBy:
+                reviewed = true
+                reviewers := network[commit.Author.Name]
+                if reviewers == nil{
+                    reviewers = make(map[string]int)
+                    network[commit.Author.Name] = reviewers
+                }
+                reviewers[trailer.Name] += 1
+            }
+        }
+
+        for name := range(credited){
+            contributors[name] += 1
+        }
+
+        if coAuthored{
+            coAuthoredCount += 1
+        }
+
+        if reviewed{
+            reviewedCount += 1
+        }
+
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    // count distinct reviewers
+    reviewers := make(map[string]bool)
+    for _, authorReviewers := range(network){
+        for reviewer := range(authorReviewers){
+            reviewers[reviewer] = true
+        }
+    }
+
+    // calculate ratios
+    coAuthoredRatio := 0.0
+    reviewCoverage := 0.0
+    if commitCount > 0{
+        coAuthoredRatio = float64(coAuthoredCount) / float64(commitCount)
+        reviewCoverage = float64(reviewedCount) / float64(commitCount)
+    }
+
+    credits := 0
+    for _, v := range(contributors){
+        credits += v
+    }
+
+    avgCommits := 0.0
+    if len(contributors) > 0{
+        avgCommits = float64(credits) / float64(len(contributors))
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["TrailerCounts"] = counts
+    result["CoAuthoredCommitRatio"] = coAuthoredRatio
+    result["CoAuthorContributorCount"] = len(contributors)
+    result["AvgCoAuthorContributorCommits"] = avgCommits
+    result["ReviewCoverage"] = reviewCoverage
+    result["ReviewerCount"] = len(reviewers)
+    result["ReviewerNetwork"] = network
+
+    // return result
+    return result, nil
+}
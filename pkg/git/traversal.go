@@ -0,0 +1,390 @@
+package git
+
+import (
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/object"
+    "github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// names of the ref sets available for traversal
+const (
+    RefsObjects = "objects"   // every commit object in storage
+    RefsHead = "head"         // commits reachable from HEAD or opts.Rev
+    RefsBranches = "branches" // commits reachable from local branches
+    RefsRemotes = "remotes"   // commits reachable from remote branches
+    RefsTags = "tags"         // commits reachable from tags
+)
+
+// Call fn for every commit of the git repository repo
+// selected by the traversal options opts.Refs and opts.FirstParent.
+// If opts.Refs is empty or contains RefsObjects, all commit objects
+// in storage are visited, unless opts.FirstParent is set,
+// in which case the mainline of HEAD is used.
+// Only commits in the history range given by opts.RangeBase,
+// opts.Since and opts.Until are passed to fn.
+// Iteration stops without error if fn returns storer.ErrStop.
+func ForEachCommit(repo *git.Repository, opts util.Options, fn func(*object.Commit) error) error{
+    // collect start commits of the ref sets
+    starts, all, err := traversalStarts(repo, opts)
+
+    if err != nil{
+        return err
+    }
+
+    // collect commits excluded by the range base
+    excluded, err := rangeExcluded(repo, opts)
+
+    if err != nil{
+        return err
+    }
+
+    // iterate over all commit objects
+    if all{
+        commitIter, err := repo.CommitObjects()
+
+        if err != nil{
+            return err
+        }
+
+        return commitIter.ForEach(func(commit *object.Commit) error{
+            if excluded[commit.Hash] || !inDateRange(commit, opts){
+                return nil
+            }
+
+            return fn(commit)
+        })
+    }
+
+    // walk the history from the start commits
+    seen := make(map[plumbing.Hash]bool)
+    stack := starts
+    for len(stack) > 0{
+        hash := stack[len(stack) - 1]
+        stack = stack[:len(stack) - 1]
+
+        // ancestors of excluded commits are excluded as well
+        if seen[hash] || excluded[hash]{
+            continue
+        }
+        seen[hash] = true
+
+        commit, err := repo.CommitObject(hash)
+
+        // skip parents missing in shallow clones
+        if err == plumbing.ErrObjectNotFound{
+            continue
+        }
+
+        if err != nil{
+            return err
+        }
+
+        // dates are not monotonic, so the walk continues past them
+        if inDateRange(commit, opts){
+            err = fn(commit)
+
+            if err == storer.ErrStop{
+                return nil
+            }
+
+            if err != nil{
+                return err
+            }
+        }
+
+        // add parents in reverse to visit the first parent next
+        parents := TraversedParents(commit, opts)
+        for i := len(parents) - 1; i >= 0; i--{
+            stack = append(stack, parents[i])
+        }
+    }
+
+    return nil
+}
+
+// Return the parents of commit followed by the traversal.
+func TraversedParents(commit *object.Commit, opts util.Options) []plumbing.Hash{
+    if opts.FirstParent && len(commit.ParentHashes) > 1{
+        return commit.ParentHashes[:1]
+    }
+
+    return commit.ParentHashes
+}
+
+// Check the ref sets given by refs. Returns an error for
+// unknown sets and for combining objects with other sets,
+// since visiting every commit object includes all of them.
+func CheckRefs(refs []string) error{
+    for _, set := range(refs){
+        switch set{
+        case RefsObjects:
+            if len(refs) > 1{
+                return errors.New("ref set objects cannot be combined with other ref sets")
+            }
+        case RefsHead, RefsBranches, RefsRemotes, RefsTags:
+        default:
+            return errors.New("unknown ref set: " + set)
+        }
+    }
+
+    return nil
+}
+
+// Describe the traversal selected by opts.
+func TraversalMode(opts util.Options) string{
+    refs := opts.Refs
+    if opts.RangeBase != ""{
+        refs = []string{RefsHead}
+    } else if len(refs) == 0 || util.ContainsString(refs, RefsObjects){
+        if !opts.FirstParent{
+            return RefsObjects
+        }
+
+        refs = []string{RefsHead}
+    }
+
+    mode := strings.Join(refs, ",")
+    if opts.FirstParent{
+        mode += " first-parent"
+    }
+
+    return mode
+}
+
+// Describe the history range selected by opts.
+// Returns an empty string if the history is not restricted.
+func HistoryRange(opts util.Options) string{
+    var parts []string
+    if opts.RangeBase != ""{
+        tip := opts.Rev
+        if tip == ""{
+            tip = "HEAD"
+        }
+
+        parts = append(parts, opts.RangeBase + ".." + tip)
+    }
+
+    if !opts.Since.IsZero(){
+        parts = append(parts, "since " + opts.Since.Format(time.RFC3339))
+    }
+
+    if !opts.Until.IsZero(){
+        parts = append(parts, "until " + opts.Until.Format(time.RFC3339))
+    }
+
+    return strings.Join(parts, " ")
+}
+
+// Test if the history is restricted to a range by opts.
+func IsHistoryRestricted(opts util.Options) bool{
+    return opts.RangeBase != "" || !opts.Since.IsZero() || !opts.Until.IsZero()
+}
+
+// Test if the author date of commit lies
+// between opts.Since and opts.Until.
+func inDateRange(commit *object.Commit, opts util.Options) bool{
+    when := commit.Author.When
+    if !opts.Since.IsZero() && when.Before(opts.Since){
+        return false
+    }
+
+    if !opts.Until.IsZero() && when.After(opts.Until){
+        return false
+    }
+
+    return true
+}
+
+// Collect the commits reachable from opts.RangeBase,
+// which are left out of the traversal.
+func rangeExcluded(repo *git.Repository, opts util.Options) (map[plumbing.Hash]bool, error){
+    excluded := make(map[plumbing.Hash]bool)
+    if opts.RangeBase == ""{
+        return excluded, nil
+    }
+
+    hash, err := repo.ResolveRevision(plumbing.Revision(opts.RangeBase))
+
+    if err != nil{
+        return nil, errors.New("resolving " + opts.RangeBase + ": " + err.Error())
+    }
+
+    base, ok := peelToCommit(repo, *hash)
+    if !ok{
+        return nil, errors.New(opts.RangeBase + " is not a commit")
+    }
+
+    // mark all ancestors of the base
+    stack := []plumbing.Hash{base}
+    for len(stack) > 0{
+        hash := stack[len(stack) - 1]
+        stack = stack[:len(stack) - 1]
+
+        if excluded[hash]{
+            continue
+        }
+        excluded[hash] = true
+
+        commit, err := repo.CommitObject(hash)
+
+        if err == plumbing.ErrObjectNotFound{
+            continue
+        }
+
+        if err != nil{
+            return nil, err
+        }
+
+        stack = append(stack, commit.ParentHashes...)
+    }
+
+    return excluded, nil
+}
+
+// Collect the commits the traversal starts at.
+// Returns true instead if all commit objects are to be visited.
+// A range base restricts the traversal to the range tip.
+func traversalStarts(repo *git.Repository, opts util.Options) ([]plumbing.Hash, bool, error){
+    refs := opts.Refs
+    if opts.RangeBase != ""{
+        refs = []string{RefsHead}
+    } else if len(refs) == 0 || util.ContainsString(refs, RefsObjects){
+        if !opts.FirstParent{
+            return nil, true, nil
+        }
+
+        refs = []string{RefsHead}
+    }
+
+    var starts []plumbing.Hash
+    for _, set := range(refs){
+        switch set{
+        case RefsHead:
+            headCommit, err := ResolveHead(repo, opts)
+
+            if err != nil{
+                return nil, false, err
+            }
+
+            starts = append(starts, headCommit.Hash)
+        case RefsBranches, RefsRemotes, RefsTags:
+            refIter, err := repo.References()
+
+            if err != nil{
+                return nil, false, err
+            }
+
+            err = refIter.ForEach(func(ref *plumbing.Reference) error{
+                if ref.Type() != plumbing.HashReference{
+                    return nil
+                }
+
+                name := ref.Name()
+                if (set == RefsBranches && name.IsBranch()) || (set == RefsRemotes && name.IsRemote()) || (set == RefsTags && name.IsTag()){
+                    hash, ok := peelToCommit(repo, ref.Hash())
+                    if ok{
+                        starts = append(starts, hash)
+                    }
+                }
+
+                return nil
+            })
+
+            if err != nil{
+                return nil, false, err
+            }
+        default:
+            return nil, false, errors.New("unknown ref set: " + set)
+        }
+    }
+
+    return starts, false, nil
+}
+
+// Return the analysed head commit of repo, which is
+// the revision opts.Rev if set and HEAD otherwise.
+// If opts.Until is set, the last first-parent
+// ancestor authored before it is returned instead.
+func ResolveHead(repo *git.Repository, opts util.Options) (*object.Commit, error){
+    var headCommit *object.Commit
+    if opts.Rev == ""{
+        headRef, err := repo.Head()
+
+        if err != nil{
+            return nil, err
+        }
+
+        headCommit, err = repo.CommitObject(headRef.Hash())
+
+        if err != nil{
+            return nil, err
+        }
+    } else{
+        hash, err := repo.ResolveRevision(plumbing.Revision(opts.Rev))
+
+        if err != nil{
+            return nil, errors.New("resolving " + opts.Rev + ": " + err.Error())
+        }
+
+        // follow annotated tags
+        commitHash, ok := peelToCommit(repo, *hash)
+        if !ok{
+            return nil, errors.New(opts.Rev + " is not a commit")
+        }
+
+        headCommit, err = repo.CommitObject(commitHash)
+
+        if err != nil{
+            return nil, err
+        }
+    }
+
+    // go back to the cut-off date
+    for !opts.Until.IsZero() && headCommit.Author.When.After(opts.Until){
+        if headCommit.NumParents() == 0{
+            return nil, errors.New("no commit before " + opts.Until.Format(time.RFC3339))
+        }
+
+        parent, err := headCommit.Parent(0)
+
+        if err != nil{
+            return nil, err
+        }
+
+        headCommit = parent
+    }
+
+    return headCommit, nil
+}
+
+// Resolve the object hash to a commit,
+// following annotated tags.
+// Returns false if hash does not point to a commit.
+func peelToCommit(repo *git.Repository, hash plumbing.Hash) (plumbing.Hash, bool){
+    tag, err := repo.TagObject(hash)
+
+    if err == nil{
+        commit, err := tag.Commit()
+
+        if err != nil{
+            return plumbing.ZeroHash, false
+        }
+
+        return commit.Hash, true
+    }
+
+    _, err = repo.CommitObject(hash)
+
+    if err != nil{
+        return plumbing.ZeroHash, false
+    }
+
+    return hash, true
+}
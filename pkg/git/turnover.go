@@ -0,0 +1,167 @@
+package git
+
+import (
+    "sort"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// struct describing the activity of a contributor
+type contributorActivity struct{
+    first time.Time
+    last time.Time
+    commits int
+}
+
+// Analyse contributor turnover and retention in history.
+// Contributors leave if they made no commit for opts.InactivityDays
+// days before the end of the history. Core contributors are the
+// smallest group of contributors accounting for opts.CoreThreshold
+// of all non-merge commits.
+// Outputs a map with the following fields:
+//   YearlyNewcomers            map[int]int
+//   YearlyLeavers              map[int]int
+//   LeaverCount                int
+//   ActiveContributorCount     int
+//   RetentionCurve             []float64
+//   CoreContributorCount       int
+//   PeripheralContributorCount int
+//   KnowledgeLossFileCount     int
+//   KnowledgeLossRatio         float64
+// RetentionCurve[k] is the share of contributors still active k years
+// after their first commit, among those who started at least k years
+// before the end of the history. KnowledgeLossFileCount counts the
+// source files in headFiles whose main author has left.
+func AnalyseTurnover(history []*HistoryCommit, headFiles map[string]bool, opts util.Options) map[string]interface{}{
+    util.PrintDebug("analysing contributor turnover", opts)
+
+    // collect contributor activity of non-merge commits
+    var end time.Time
+    activity := make(map[string]*contributorActivity)
+    commitCount := 0
+    for _, commit := range(history){
+        if len(commit.Parents) > 1{
+            continue
+        }
+        commitCount += 1
+
+        if commit.When.After(end){
+            end = commit.When
+        }
+
+        a := activity[commit.Author]
+        if a == nil{
+            a = &contributorActivity{first: commit.When, last: commit.When}
+            activity[commit.Author] = a
+        }
+
+        if commit.When.Before(a.first){
+            a.first = commit.When
+        }
+
+        if commit.When.After(a.last){
+            a.last = commit.When
+        }
+
+        a.commits += 1
+    }
+
+    // count newcomers and leavers per year
+    inactivity := time.Duration(opts.InactivityDays) * 24 * time.Hour
+    newcomers := make(map[int]int)
+    leavers := make(map[int]int)
+    left := make(map[string]bool)
+    for name, a := range(activity){
+        newcomers[a.first.Year()] += 1
+
+        if end.Sub(a.last) > inactivity{
+            leavers[a.last.Year()] += 1
+            left[name] = true
+        }
+    }
+
+    // calculate the retention curve
+    var retention []float64
+    for k := 0; ; k++{
+        offset := time.Duration(k) * 365 * 24 * time.Hour
+        started := 0
+        retained := 0
+        for _, a := range(activity){
+            if end.Sub(a.first) < offset{
+                continue
+            }
+
+            started += 1
+            if a.last.Sub(a.first) >= offset{
+                retained += 1
+            }
+        }
+
+        if started == 0{
+            break
+        }
+
+        retention = append(retention, float64(retained) / float64(started))
+    }
+
+    // find core contributors
+    commits := make([]int, 0, len(activity))
+    for _, a := range(activity){
+        commits = append(commits, a.commits)
+    }
+    sort.Sort(sort.Reverse(sort.IntSlice(commits)))
+
+    coreCount := 0
+    coreCommits := 0
+    for _, c := range(commits){
+        if float64(coreCommits) >= opts.CoreThreshold * float64(commitCount){
+            break
+        }
+
+        coreCount += 1
+        coreCommits += c
+    }
+
+    // find existing files whose main author has left
+    fileCount := 0
+    lostCount := 0
+    for file, authors := range(FileAuthors(history, opts)){
+        if !headFiles[file]{
+            continue
+        }
+        fileCount += 1
+
+        mainAuthor := ""
+        mainCommits := 0
+        for author, c := range(authors){
+            if c > mainCommits || (c == mainCommits && author < mainAuthor){
+                mainAuthor = author
+                mainCommits = c
+            }
+        }
+
+        if left[mainAuthor]{
+            lostCount += 1
+        }
+    }
+
+    lostRatio := 0.0
+    if fileCount > 0{
+        lostRatio = float64(lostCount) / float64(fileCount)
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["YearlyNewcomers"] = newcomers
+    result["YearlyLeavers"] = leavers
+    result["LeaverCount"] = len(left)
+    result["ActiveContributorCount"] = len(activity) - len(left)
+    result["RetentionCurve"] = retention
+    result["CoreContributorCount"] = coreCount
+    result["PeripheralContributorCount"] = len(activity) - coreCount
+    result["KnowledgeLossFileCount"] = lostCount
+    result["KnowledgeLossRatio"] = lostRatio
+
+    return result
+}
@@ -5,8 +5,12 @@ import (
     "os"
     "os/exec"
     "path"
+    "path/filepath"
     "strings"
+    "sync"
     
+    "github.com/j-bhm/CppGitMining/pkg/filter"
+    "github.com/j-bhm/CppGitMining/pkg/lang"
     "github.com/j-bhm/CppGitMining/pkg/util"
     
     "github.com/go-git/go-git/v5"
@@ -15,11 +19,15 @@ import (
 // directory with git repositories
 const GitDir = util.OutDir + "/gits"
 
+// directory with worktrees of analysed revisions
+const WorktreeDir = util.OutDir + "/worktrees"
+
 // Clone all repositories given in urls and
 // build each one with the corresponding command
 // in commands.
-// Returns the paths to the cloned repositories.
-func LoadRepos(urls []string, commands []string, opts util.Options) (paths []string) {
+// Returns the paths to the cloned repositories
+// and their build commands.
+func LoadRepos(urls []string, commands []string, opts util.Options) (paths []string, pathCommands []string) {
     // test for correct input
     if len(urls) != len(commands){
         panic("loading repositories: unequal number of urls and build commands")
@@ -48,6 +56,7 @@ func LoadRepos(urls []string, commands []string, opts util.Options) (paths []str
             } else{
                 // add dir to the results
                 paths = append(paths, dir)
+                pathCommands = append(pathCommands, commands[i])
                 util.PrintDebug("repository already exists", opts)
                 continue
             }
@@ -64,10 +73,11 @@ func LoadRepos(urls []string, commands []string, opts util.Options) (paths []str
         
         // add dir to the results
         paths = append(paths, dir)
+        pathCommands = append(pathCommands, commands[i])
     }
     
     // return all paths
-    return paths
+    return paths, pathCommands
 }
 
 
@@ -131,3 +141,109 @@ func BuildRepo(path string, command string, opts util.Options) error {
     // return success
     return nil
 }
+
+// Check out the revision rev of the repository in path
+// into a new linked worktree in dir.
+// Requires the git command line tool.
+func AddWorktree(path, dir, rev string, opts util.Options) error {
+    absDir, err := filepath.Abs(dir)
+    
+    if err != nil{
+        return err
+    }
+    
+    // remove leftovers of previous runs
+    RemoveWorktree(path, dir, opts)
+    
+    err = os.MkdirAll(filepath.Dir(absDir), 0750)
+    
+    if err != nil{
+        return err
+    }
+    
+    // create worktree
+    cmd := exec.Command("git", "worktree", "add", "--force", "--detach", absDir, rev)
+    cmd.Dir = path
+    
+    return util.RunCmd(cmd, opts)
+}
+
+// Remove the linked worktree in dir
+// of the repository in path.
+func RemoveWorktree(path, dir string, opts util.Options) error {
+    absDir, err := filepath.Abs(dir)
+    
+    if err != nil{
+        return err
+    }
+    
+    // remove the directory and prune the worktree list
+    err = os.RemoveAll(absDir)
+    
+    if err != nil{
+        return err
+    }
+    
+    cmd := exec.Command("git", "worktree", "prune")
+    cmd.Dir = path
+    
+    return util.RunCmd(cmd, opts)
+}
+
+// Test if the file given by name is a source or header
+// file of the language profile selected by opts
+// that passes the path filters of opts.
+func IsSourceFile(name string, opts util.Options) bool {
+    return lang.ForOptions(opts).Matches(name) && filter.Included(name, opts)
+}
+
+// Copy all fields of src into dst.
+func mergeResult(dst, src map[string]interface{}){
+    for k, v := range(src){
+        dst[k] = v
+    }
+}
+
+// Call fn for every index in [0, n) using opts.Jobs workers.
+// Every worker opens a separate instance of the repository
+// in path, since go-git repositories are not thread-safe.
+func forEachParallel(path string, n int, opts util.Options, fn func(repo *git.Repository, i int)) error{
+    jobs := opts.Jobs
+    if jobs < 1{
+        jobs = 1
+    }
+    
+    // open repositories
+    repos := make([]*git.Repository, jobs)
+    for i := range(repos){
+        repo, err := git.PlainOpen(path)
+        
+        if err != nil{
+            return err
+        }
+        
+        repos[i] = repo
+    }
+    
+    // fill the work queue
+    queue := make(chan int, n)
+    for i := 0; i < n; i++{
+        queue <- i
+    }
+    close(queue)
+    
+    // run workers
+    var wg sync.WaitGroup
+    for _, repo := range(repos){
+        wg.Add(1)
+        go func(repo *git.Repository){
+            defer wg.Done()
+            for i := range(queue){
+                fn(repo, i)
+            }
+        }(repo)
+    }
+    wg.Wait()
+    
+    return nil
+}
@@ -0,0 +1,290 @@
+package hotspot
+
+import (
+    "errors"
+    "math"
+    "path/filepath"
+    "sort"
+
+    "github.com/j-bhm/CppGitMining/pkg/gct"
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/sct"
+    "github.com/j-bhm/CppGitMining/pkg/source"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+)
+
+// normalisations of the hotspot components
+const (
+    NormMax = "max"    // divide by the maximum value
+    NormRank = "rank"  // use the percentile rank
+    NormNone = "none"  // use the raw values
+)
+
+// struct describing a file in the hotspot ranking
+type Hotspot struct{
+    File string
+    Score float64
+    Changes int    // number of commits changing the file
+    Churn int      // number of added and deleted lines
+    Scd float64    // static coupling degree
+    Gcd float64    // git coupling degree
+    Complexity float64 // sum of the cyclomatic complexities of the functions
+}
+
+// Run the hotspot analysis on the repository in path.
+// Uses the outputs of previous sct and gct runs if available,
+// missing outputs are left out of the ranking. Mass-change commits
+// are left out of the churn. On restricted histories or without
+// mass-change commits the native change coupling replaces the gct output.
+// If opts.HotspotComplexityWeight is set, the cyclomatic complexity of
+// the files at the analysed head is added to the ranking.
+// Outputs a map with the following fields:
+//   HotspotFileCount int
+//   HotspotSources   []string
+//   Hotspots         []Hotspot
+func RunHotspotAnalysis(path string, opts util.Options) (map[string]interface{}, error){
+    // load history
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, err := git.LoadHistory(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, _, err = git.RemoveMassChanges(path, history, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    headFiles, err := git.HeadFiles(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // load coupling degrees of previous runs
+    var scds map[string]float64
+    sctJson, err := sct.LoadSctOutput(path, opts)
+    if err == nil{
+        sctJson, err = sct.FilterSctJson(sctJson, path, opts)
+    }
+
+    if err == nil{
+        scds = make(map[string]float64)
+        paths := sct.SctNodePaths(sctJson, path)
+        for id, degree := range(sct.SctNodeDegrees(sctJson)){
+            scds[paths[id]] += degree
+        }
+    } else{
+        util.PrintDebug("no sct output for hotspots: " + err.Error(), opts)
+    }
+
+    var gcds map[string]float64
+    gctJson, err := gct.LoadFilteredGctJson(path, opts)
+
+    if err == nil{
+        gcds = make(map[string]float64)
+        for id, degree := range(gct.GctNodeDegrees(gctJson)){
+            gcds[filepath.ToSlash(filepath.Clean(id))] += degree
+        }
+    } else{
+        util.PrintDebug("no gct output for hotspots: " + err.Error(), opts)
+    }
+
+    // calculate complexities of the source files
+    var complexities map[string]float64
+    if opts.HotspotComplexityWeight != 0{
+        util.PrintDebug("calculating file complexities for hotspots", opts)
+        complexities, err = source.FileComplexities(path, opts)
+
+        if err != nil{
+            return nil, err
+        }
+    }
+
+    // rank hotspots
+    util.PrintDebug("ranking hotspots", opts)
+    return AnalyseHotspots(history, headFiles, scds, gcds, complexities, opts)
+}
+
+// Rank the source files of history existing in headFiles by combining
+// their change frequency, or churn if opts.HotspotChurn is set,
+// with their static coupling degree scds, git coupling degree gcds
+// and cyclomatic complexity complexities. The components are
+// normalised with opts.HotspotNorm and weighted with
+// opts.HotspotChangeWeight, opts.HotspotSctWeight,
+// opts.HotspotGctWeight and opts.HotspotComplexityWeight.
+// Nil maps are left out.
+// Returns a map with the following fields:
+//   HotspotFileCount int
+//   HotspotSources   []string
+//   Hotspots         []Hotspot
+// Hotspots lists the opts.TopN files with the highest score.
+func AnalyseHotspots(history []*git.HistoryCommit, headFiles map[string]bool, scds, gcds, complexities map[string]float64, opts util.Options) (map[string]interface{}, error){
+    // collect changes and churn of existing files
+    files := make(map[string]*Hotspot)
+    for _, commit := range(history){
+        for _, change := range(commit.Changes){
+            if !headFiles[change.Path] || !git.IsSourceFile(change.Path, opts){
+                continue
+            }
+
+            h := files[change.Path]
+            if h == nil{
+                h = &Hotspot{File: change.Path}
+                files[change.Path] = h
+            }
+
+            h.Changes += 1
+            h.Churn += change.Additions + change.Deletions
+        }
+    }
+
+    hotspots := make([]*Hotspot, 0, len(files))
+    for _, h := range(files){
+        h.Scd = scds[h.File]
+        h.Gcd = gcds[h.File]
+        h.Complexity = complexities[h.File]
+        hotspots = append(hotspots, h)
+    }
+
+    // collect the components
+    changes := make([]float64, len(hotspots))
+    static := make([]float64, len(hotspots))
+    coupling := make([]float64, len(hotspots))
+    complexity := make([]float64, len(hotspots))
+    for i, h := range(hotspots){
+        changes[i] = float64(h.Changes)
+        if opts.HotspotChurn{
+            changes[i] = float64(h.Churn)
+        }
+
+        static[i] = h.Scd
+        coupling[i] = h.Gcd
+        complexity[i] = h.Complexity
+    }
+
+    // normalise and weight the components
+    sources := []string{"changes"}
+    if opts.HotspotChurn{
+        sources[0] = "churn"
+    }
+
+    components := [][]float64{changes}
+    weights := []float64{opts.HotspotChangeWeight}
+    if scds != nil{
+        sources = append(sources, "sct")
+        components = append(components, static)
+        weights = append(weights, opts.HotspotSctWeight)
+    }
+
+    if gcds != nil{
+        sources = append(sources, "gct")
+        components = append(components, coupling)
+        weights = append(weights, opts.HotspotGctWeight)
+    }
+
+    if complexities != nil{
+        sources = append(sources, "complexity")
+        components = append(components, complexity)
+        weights = append(weights, opts.HotspotComplexityWeight)
+    }
+
+    for c, values := range(components){
+        normalised, err := normalise(values, opts.HotspotNorm)
+
+        if err != nil{
+            return nil, err
+        }
+
+        for i := range(hotspots){
+            hotspots[i].Score += weights[c] * normalised[i]
+        }
+    }
+
+    // rank files by score
+    sort.Slice(hotspots, func(i, j int) bool{
+        if hotspots[i].Score != hotspots[j].Score{
+            return hotspots[i].Score > hotspots[j].Score
+        }
+
+        return hotspots[i].File < hotspots[j].File
+    })
+
+    top := make([]Hotspot, 0, len(hotspots))
+    for _, h := range(hotspots){
+        if opts.TopN > 0 && len(top) == opts.TopN{
+            break
+        }
+
+        top = append(top, *h)
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["HotspotFileCount"] = len(hotspots)
+    result["HotspotSources"] = sources
+    result["Hotspots"] = top
+
+    return result, nil
+}
+
+// Normalise values with the given normalisation.
+func normalise(values []float64, norm string) ([]float64, error){
+    result := make([]float64, len(values))
+    switch norm{
+    case NormNone:
+        copy(result, values)
+    case NormMax, "":
+        maximum := 0.0
+        for _, v := range(values){
+            maximum = math.Max(maximum, v)
+        }
+
+        if maximum > 0{
+            for i, v := range(values){
+                result[i] = v / maximum
+            }
+        }
+    case NormRank:
+        // sort indices by value
+        indices := make([]int, len(values))
+        for i := range(indices){
+            indices[i] = i
+        }
+
+        sort.Slice(indices, func(a, b int) bool{
+            return values[indices[a]] < values[indices[b]]
+        })
+
+        // assign average ranks to equal values
+        for start := 0; start < len(indices); {
+            end := start
+            for end + 1 < len(indices) && values[indices[end + 1]] == values[indices[start]]{
+                end += 1
+            }
+
+            rank := 0.0
+            if len(indices) > 1{
+                rank = float64(start + end) / 2 / float64(len(indices) - 1)
+            }
+
+            for k := start; k <= end; k++{
+                result[indices[k]] = rank
+            }
+            start = end + 1
+        }
+    default:
+        return nil, errors.New("unknown hotspot normalisation: " + norm)
+    }
+
+    return result, nil
+}
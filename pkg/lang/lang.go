@@ -0,0 +1,137 @@
+package lang
+
+import (
+    "errors"
+    "os"
+    "path"
+    "sort"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// struct describing the files of a language
+type Profile struct{
+    Name string
+    Sources []string    // extensions of source files
+    Headers []string    // extensions of header files
+    SctLanguage string  // language argument of the StaticCouplingTool
+}
+
+// name of the profile used if none is selected
+const DefaultProfile = "cpp"
+
+// name of the file recording the profile of a tool output
+const ProfileFile = "profile"
+
+// available language profiles
+var Profiles = map[string]Profile{
+    "c": {
+        Name: "c",
+        Sources: []string{".c"},
+        Headers: []string{".h"},
+        SctLanguage: "cpp",
+    },
+    "cpp": {
+        Name: "cpp",
+        Sources: []string{".c", ".cc", ".cpp", ".cxx", ".c++", ".C"},
+        Headers: []string{".h", ".hh", ".hpp", ".hxx", ".h++", ".H", ".inl", ".ipp", ".tpp"},
+        SctLanguage: "cpp",
+    },
+    // extensions used before the introduction of profiles
+    "cpp-classic": {
+        Name: "cpp-classic",
+        Sources: []string{".c", ".cpp"},
+        Headers: []string{".h", ".hpp"},
+        SctLanguage: "cpp",
+    },
+}
+
+// Return the profile with the given name.
+// An empty name selects the DefaultProfile.
+func Lookup(name string) (Profile, error){
+    if name == ""{
+        name = DefaultProfile
+    }
+
+    profile, ok := Profiles[name]
+    if !ok{
+        return Profile{}, errors.New("unknown language profile: " + name)
+    }
+
+    return profile, nil
+}
+
+// Return the profile selected by opts.Language,
+// or the DefaultProfile if it is unknown.
+func ForOptions(opts util.Options) Profile{
+    profile, err := Lookup(opts.Language)
+
+    if err != nil{
+        return Profiles[DefaultProfile]
+    }
+
+    return profile
+}
+
+// Return the names of all profiles in sorted order.
+func ProfileNames() []string{
+    names := make([]string, 0, len(Profiles))
+    for name := range(Profiles){
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    return names
+}
+
+// Return the source and header extensions of the profile.
+func (p Profile) Extensions() []string{
+    extensions := make([]string, 0, len(p.Sources) + len(p.Headers))
+    extensions = append(extensions, p.Sources...)
+    return append(extensions, p.Headers...)
+}
+
+// Return a key identifying the profile and the files it selects.
+func (p Profile) Key() string{
+    return p.Name + " " + p.SctLanguage + " " + strings.Join(p.Extensions(), ",")
+}
+
+// Record the profile selected by opts in the
+// ProfileFile of the output directory dir.
+func WriteProfileFile(dir string, opts util.Options) error{
+    return os.WriteFile(dir + "/" + ProfileFile, []byte(ForOptions(opts).Key() + "\n"), 0640)
+}
+
+// Check that the output in the directory dir was created with
+// the profile selected by opts. Returns an error if the ProfileFile
+// is missing or records a different profile.
+func CheckProfileFile(dir string, opts util.Options) error{
+    data, err := os.ReadFile(dir + "/" + ProfileFile)
+
+    if err != nil{
+        return err
+    }
+
+    if strings.TrimSpace(string(data)) != ForOptions(opts).Key(){
+        return errors.New("output was created with a different language profile")
+    }
+
+    return nil
+}
+
+// Test if the file given by name is a source file of the profile.
+func (p Profile) IsSource(name string) bool{
+    return util.ContainsString(p.Sources, path.Ext(name))
+}
+
+// Test if the file given by name is a header file of the profile.
+func (p Profile) IsHeader(name string) bool{
+    return util.ContainsString(p.Headers, path.Ext(name))
+}
+
+// Test if the file given by name is a source
+// or header file of the profile.
+func (p Profile) Matches(name string) bool{
+    return p.IsSource(name) || p.IsHeader(name)
+}
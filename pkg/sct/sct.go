@@ -4,10 +4,13 @@ import (
     "errors"
     "encoding/json"
     "path/filepath"
+    "strings"
 	"os"
 	"os/exec"
 	
 	"github.com/j-bhm/CppGitMining/pkg/allen"
+	"github.com/j-bhm/CppGitMining/pkg/filter"
+	"github.com/j-bhm/CppGitMining/pkg/lang"
 	"github.com/j-bhm/CppGitMining/pkg/util"
 )
 
@@ -44,6 +47,8 @@ const SctOutDir string = util.OutDir + "/sct"
 //   AvgScd        float64
 //   SizeSct       float64
 //   ComplexitySct float64
+// The output of a previous run is reused if it was created
+// with the language profile selected by opts.
 func RunSctAnalysis(path string, opts util.Options) (map[string]interface{}, error){
     // directory for sct output
     outputDir := SctOutDir + "/" + filepath.Base(path)
@@ -63,11 +68,17 @@ func RunSctAnalysis(path string, opts util.Options) (map[string]interface{}, err
         } else{
         // try parsing old output
         util.PrintDebug("parsing old sct result", opts)
-        sctJson, err := ParseSctOutput(outputDir + "/0")
+        sctJson, err := LoadSctOutput(path, opts)
         
         if err == nil{
                 // analyse output
                 util.PrintDebug("using old result for analysis", opts)
+                sctJson, err = FilterSctJson(sctJson, path, opts)
+                
+                if err != nil{
+                    return nil, err
+                }
+                
                 sctResult := AnalyseSctOutput(sctJson)
                 
                 // return result
@@ -100,6 +111,13 @@ func RunSctAnalysis(path string, opts util.Options) (map[string]interface{}, err
         return nil, err
     }
     
+    // record the language profile of the output
+    err = lang.WriteProfileFile(outputDir, opts)
+    
+    if err != nil{
+        return nil, err
+    }
+    
     // parse sct output
     util.PrintDebug("parsing sct output", opts)
     sctJson, err := ParseSctOutput(outputDir + "/0")
@@ -110,6 +128,12 @@ func RunSctAnalysis(path string, opts util.Options) (map[string]interface{}, err
     
     // analyse sct output
     util.PrintDebug("analysing sct output", opts)
+    sctJson, err = FilterSctJson(sctJson, path, opts)
+    
+    if err != nil{
+        return nil, err
+    }
+    
     sctResult := AnalyseSctOutput(sctJson)
     
     // return result
@@ -157,9 +181,11 @@ func AnalyseSctOutput(sctJson *SctJson) map[string]interface{}{
 // Run the StaticCouplingTool on the
 // repository specified in path and
 // save the results in the directory outDir.
+// The language argument is taken from the
+// language profile selected by opts.
 func RunSct(path, outDir string, opts util.Options) error{
     // create command executing sct
-    cmdArgs := []string{"-m", "-l", "cpp", "-p", path, "-o", outDir}
+    cmdArgs := []string{"-m", "-l", lang.ForOptions(opts).SctLanguage, "-p", path, "-o", outDir}
     cmd := exec.Command(opts.Sct, cmdArgs...)
     
     // run the command
@@ -176,6 +202,8 @@ func RunSct(path, outDir string, opts util.Options) error{
 // Convert the json result from the sct
 // into the graph representation used
 // for analysis.
+// The nodes of the graph are ordered like
+// the node ids returned by uniqueNodeIds.
 func ConvertSctToGraph(json *SctJson) allen.Graph{
 
     // copy nodes
@@ -195,10 +223,10 @@ func ConvertSctToGraph(json *SctJson) allen.Graph{
         endNode.InEdges = append(endNode.InEdges, allen.GraphEdge{startNode, edge.Weight})
     }
     
-    // create slice
+    // create slice in node order
     graph := make([]*allen.GraphNode, 0, len(graphMap))
-    for _, node := range(graphMap){
-        graph = append(graph, node)
+    for _, id := range(uniqueNodeIds(json)){
+        graph = append(graph, graphMap[id])
     }
 
     // return result
@@ -231,3 +259,115 @@ func ParseSctOutput(dir string) (*SctJson, error) {
     // return data
     return sctJson, nil
 }
+
+// Map the node ids of a sct json to the paths of
+// the corresponding files relative to the repository
+// root. Node labels are expected to be file paths,
+// nodes outside of root are mapped to an empty string.
+func SctNodePaths(sctJson *SctJson, root string) map[string]string{
+    result := make(map[string]string)
+    absRoot, err := filepath.Abs(root)
+    
+    if err != nil{
+        absRoot = root
+    }
+    
+    for _, node := range(sctJson.Nodes){
+        path := node.Label
+        if filepath.IsAbs(path){
+            rel, err := filepath.Rel(absRoot, path)
+            
+            if err != nil{
+                rel = ".."
+            }
+            
+            path = rel
+        }
+        
+        path = filepath.ToSlash(filepath.Clean(path))
+        if path == ".." || strings.HasPrefix(path, "../"){
+            path = ""
+        }
+        
+        result[node.Id] = path
+    }
+    
+    return result
+}
+
+// Remove the nodes of files in the repository root
+// excluded by the path filters of opts and their
+// edges from a sct json. Nodes outside of root are kept.
+// Returns an error if no nodes remain.
+func FilterSctJson(sctJson *SctJson, root string, opts util.Options) (*SctJson, error){
+    if !filter.IsFiltering(opts){
+        return sctJson, nil
+    }
+    
+    // select nodes
+    paths := SctNodePaths(sctJson, root)
+    result := new(SctJson)
+    kept := make(map[string]bool)
+    for _, node := range(sctJson.Nodes){
+        path := paths[node.Id]
+        if path == "" || filter.Included(path, opts){
+            kept[node.Id] = true
+            result.Nodes = append(result.Nodes, node)
+        }
+    }
+    
+    // select edges between kept nodes
+    for _, edge := range(sctJson.Edges){
+        if kept[edge.Start] && kept[edge.End]{
+            result.Edges = append(result.Edges, edge)
+        }
+    }
+    
+    if len(result.Nodes) == 0{
+        return nil, errors.New("empty sct graph after path filtering")
+    }
+    
+    return result, nil
+}
+
+// Load the output of a previous sct run
+// on the repository specified in path.
+// Returns an error if the output was created
+// with another language profile than selected by opts.
+func LoadSctOutput(path string, opts util.Options) (*SctJson, error){
+    outputDir := SctOutDir + "/" + filepath.Base(path)
+    err := lang.CheckProfileFile(outputDir, opts)
+    
+    if err != nil{
+        return nil, err
+    }
+    
+    return ParseSctOutput(outputDir + "/0")
+}
+
+// Return the node ids of a sct json without
+// duplicates in the order of their first occurrence.
+func uniqueNodeIds(json *SctJson) []string{
+    seen := make(map[string]bool)
+    ids := make([]string, 0, len(json.Nodes))
+    for _, node := range(json.Nodes){
+        if !seen[node.Id]{
+            seen[node.Id] = true
+            ids = append(ids, node.Id)
+        }
+    }
+    
+    return ids
+}
+
+// Calculate the static coupling degree of every node
+// of a sct json and return a map from node ids to degrees.
+func SctNodeDegrees(json *SctJson) map[string]float64{
+    degrees := allen.SumWeights(ConvertSctToGraph(json))
+    result := make(map[string]float64)
+    for i, id := range(uniqueNodeIds(json)){
+        result[id] = degrees[i]
+    }
+    
+    return result
+}
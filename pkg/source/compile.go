@@ -0,0 +1,224 @@
+package source
+
+import (
+    "encoding/json"
+    "path/filepath"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+)
+
+// name of the compilation database in the repository root
+const CompileCommandsFile = "compile_commands.json"
+
+// flags followed by include directories
+var includeFlags = []string{"-I", "-isystem", "-iquote", "-idirafter"}
+
+// struct representing an entry of a compilation database
+type CompileCommand struct{
+    Directory string
+    File string
+    Command string
+    Arguments []string
+}
+
+// Load the compilation database in the root of the
+// repository in path at the analysed head.
+func LoadCompileCommands(path string, opts util.Options) ([]CompileCommand, error){
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    headCommit, err := git.ResolveHead(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    file, err := headCommit.File(CompileCommandsFile)
+
+    if err != nil{
+        return nil, err
+    }
+
+    data, err := file.Contents()
+
+    if err != nil{
+        return nil, err
+    }
+
+    var commands []CompileCommand
+    err = json.Unmarshal([]byte(data), &commands)
+
+    if err != nil{
+        return nil, err
+    }
+
+    return commands, nil
+}
+
+// Return the arguments of the command, splitting
+// the command line if no argument list is given.
+func (c CompileCommand) Args() []string{
+    if len(c.Arguments) > 0{
+        return c.Arguments
+    }
+
+    return splitCommand(c.Command)
+}
+
+// Return the include directories of the commands that lie
+// in the repository root as slash separated relative paths.
+// A committed database holds the absolute paths of the machine
+// it was generated on, so if its entries lie outside of root,
+// the paths are taken relative to the root of the database.
+func IncludeDirs(commands []CompileCommand, root string) []string{
+    root, err := filepath.Abs(root)
+
+    if err != nil{
+        return nil
+    }
+
+    dbRoot := commandsRoot(commands)
+    if dbRoot != "" && !isBelow(dbRoot, root){
+        root = dbRoot
+    }
+
+    var dirs []string
+    seen := make(map[string]bool)
+    for _, command := range(commands){
+        args := command.Args()
+        for i, arg := range(args){
+            dir := ""
+            for _, flag := range(includeFlags){
+                if arg == flag && i + 1 < len(args){
+                    dir = args[i + 1]
+                    break
+                } else if strings.HasPrefix(arg, flag) && len(arg) > len(flag){
+                    dir = arg[len(flag):]
+                    break
+                }
+            }
+
+            if dir == ""{
+                continue
+            }
+
+            // make the directory relative to the root
+            if !filepath.IsAbs(dir){
+                dir = filepath.Join(command.Directory, dir)
+            }
+
+            if !isBelow(dir, root){
+                continue
+            }
+
+            rel, _ := filepath.Rel(root, dir)
+            rel = filepath.ToSlash(rel)
+            if !seen[rel]{
+                seen[rel] = true
+                dirs = append(dirs, rel)
+            }
+        }
+    }
+
+    return dirs
+}
+
+// Return the longest common directory of the absolute
+// directories and files of the commands, which is taken
+// as the root of the compilation database. Returns ""
+// if the commands contain no absolute paths.
+func commandsRoot(commands []CompileCommand) string{
+    var prefix []string
+    found := false
+    for _, command := range(commands){
+        file := command.File
+        if !filepath.IsAbs(file){
+            file = filepath.Join(command.Directory, file)
+        }
+
+        for _, dir := range([]string{command.Directory, filepath.Dir(file)}){
+            if !filepath.IsAbs(dir){
+                continue
+            }
+
+            segments := strings.Split(filepath.Clean(dir), string(filepath.Separator))
+            if !found{
+                prefix = segments
+                found = true
+                continue
+            }
+
+            n := 0
+            for n < len(prefix) && n < len(segments) && prefix[n] == segments[n]{
+                n += 1
+            }
+            prefix = prefix[:n]
+        }
+    }
+
+    if !found{
+        return ""
+    }
+
+    root := strings.Join(prefix, string(filepath.Separator))
+    if root == ""{
+        return string(filepath.Separator)
+    }
+
+    return root
+}
+
+// Test if the path lies in the directory dir.
+func isBelow(path, dir string) bool{
+    rel, err := filepath.Rel(dir, path)
+    return err == nil && rel != ".." && !strings.HasPrefix(rel, ".." + string(filepath.Separator))
+}
+
+// Split a command line into arguments,
+// following quotes and backslash escapes.
+func splitCommand(command string) []string{
+    var args []string
+    var arg strings.Builder
+    inArg := false
+    quote := byte(0)
+    for i := 0; i < len(command); i++{
+        c := command[i]
+        switch{
+        case c == '\\' && quote != '\'' && i + 1 < len(command):
+            i += 1
+            arg.WriteByte(command[i])
+            inArg = true
+        case quote != 0:
+            if c == quote{
+                quote = 0
+            } else{
+                arg.WriteByte(c)
+            }
+        case c == '"' || c == '\'':
+            quote = c
+            inArg = true
+        case c == ' ' || c == '\t' || c == '\n':
+            if inArg{
+                args = append(args, arg.String())
+                arg.Reset()
+                inArg = false
+            }
+        default:
+            arg.WriteByte(c)
+            inArg = true
+        }
+    }
+
+    if inArg{
+        args = append(args, arg.String())
+    }
+
+    return args
+}
@@ -0,0 +1,369 @@
+package source
+
+import (
+    "regexp"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// language features found by special patterns
+const (
+    FeatureLambdas = "lambdas"
+    FeatureNew = "new"
+    FeatureDelete = "delete"
+    FeatureRangeFor = "range_for"
+    FeatureEnumClass = "enum_class"
+    FeatureMove = "move_semantics"
+)
+
+// language features found by their identifiers
+var featureIdentifiers = map[string][]string{
+    "templates": {"template"},
+    "exceptions": {"throw", "try", "catch"},
+    "noexcept": {"noexcept"},
+    "rtti": {"dynamic_cast", "typeid"},
+    "smart_pointers": {"unique_ptr", "shared_ptr", "weak_ptr"},
+    "smart_pointer_allocations": {"make_unique", "make_shared", "allocate_shared", "make_unique_for_overwrite", "make_shared_for_overwrite"},
+    "auto": {"auto"},
+    "constexpr": {"constexpr", "consteval", "constinit"},
+    "cpp_casts": {"static_cast", "reinterpret_cast", "const_cast"},
+    "nullptr": {"nullptr"},
+    "null_macro": {"NULL"},
+    "override": {"override", "final"},
+    "static_assert": {"static_assert"},
+    "concepts": {"concept", "requires"},
+    "coroutines": {"co_await", "co_yield", "co_return"},
+}
+
+// standard library headers by the standard introducing them
+var modernHeaders = map[string][]string{
+    "c++11": {"array", "atomic", "chrono", "condition_variable", "forward_list", "future",
+        "initializer_list", "mutex", "random", "ratio", "regex", "scoped_allocator",
+        "system_error", "thread", "tuple", "type_traits", "typeindex", "unordered_map",
+        "unordered_set", "cstdint", "cinttypes", "cuchar"},
+    "c++14": {"shared_mutex"},
+    "c++17": {"any", "charconv", "execution", "filesystem", "memory_resource", "optional",
+        "string_view", "variant"},
+    "c++20": {"barrier", "bit", "compare", "concepts", "coroutine", "format", "latch",
+        "numbers", "ranges", "semaphore", "source_location", "span", "stop_token", "syncstream",
+        "version"},
+    "c++23": {"expected", "flat_map", "flat_set", "generator", "mdspan", "print",
+        "spanstream", "stacktrace", "stdfloat"},
+}
+
+// standards in chronological order
+var standards = []string{"c++98", "c++03", "c++11", "c++14", "c++17", "c++20", "c++23", "c++26"}
+
+// matches the standard flags of gcc, clang and msvc
+var standardRegexp = regexp.MustCompile(`^[-/]std[=:](c|gnu)\+\+(\w+)$`)
+
+// aliases of draft standard names
+var standardAliases = map[string]string{
+    "0x": "11", "1y": "14", "1z": "17", "2a": "20", "2b": "23", "2c": "26", "latest": "23",
+}
+
+// struct holding the usage of a language feature
+type FeatureUsage struct{
+    Count int
+    Files int         // files using the feature
+    PerKloc float64   // uses per thousand code lines
+}
+
+// Run the language feature analysis on the source files at the
+// analysed head of the repository in path. The targeted standard
+// is inferred from the -std flags of the compilation database in
+// the root of the repository at the analysed head if available.
+// Outputs a map with the following fields:
+//   CodeLines            int
+//   Features             map[string]FeatureUsage
+//   SmartPointerRatio    float64
+//   ModernHeaders        map[string]int
+//   NewestHeaderStandard string
+//   InferredStandard     string
+//   StandardFlags        map[string]int
+func RunFeatureAnalysis(path string, opts util.Options) (map[string]interface{}, error){
+    util.PrintDebug("loading source files", opts)
+    files, err := LoadFiles(path, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    util.PrintDebug("analysing language features", opts)
+    result := AnalyseFeatures(files)
+
+    // infer the targeted standard
+    commands, err := LoadCompileCommands(path, opts)
+    if err != nil{
+        util.PrintDebug("no compilation database to infer the standard: " + err.Error(), opts)
+    }
+
+    flags := StandardFlags(commands)
+    result["InferredStandard"] = inferStandard(flags)
+    result["StandardFlags"] = flags
+
+    return result, nil
+}
+
+// Count the language features and modern standard library headers
+// used by the files. Returns a map with the fields of
+// RunFeatureAnalysis without the inferred standard. ModernHeaders
+// maps headers to the number of files including them.
+// SmartPointerRatio is the share of allocations by make_unique,
+// make_shared and similar functions among these allocations and
+// new expressions. Uses of the smart pointer types are counted
+// separately as the smart_pointers feature.
+func AnalyseFeatures(files []File) map[string]interface{}{
+    features := make(map[string]FeatureUsage)
+    headers := make(map[string]int)
+    headerStandards := make(map[string]string)
+    for standard, names := range(modernHeaders){
+        for _, name := range(names){
+            headerStandards[name] = standard
+        }
+    }
+
+    codeLines := 0
+    newest := ""
+    for _, file := range(files){
+        tokens := Tokenize(file.Content)
+        var lines []bool
+        for _, token := range(tokens){
+            if token.Kind != Comment{
+                for l := token.Line; l <= token.EndLine; l++{
+                    lines = setLine(lines, l)
+                }
+            }
+        }
+        codeLines += countLines(lines)
+
+        // count features
+        for feature, count := range(countFeatures(CodeTokens(tokens))){
+            usage := features[feature]
+            usage.Count += count
+            usage.Files += 1
+            features[feature] = usage
+        }
+
+        // collect modern headers
+        included := make(map[string]bool)
+        for _, include := range(parseIncludes(file.Content)){
+            standard, ok := headerStandards[include.Name]
+            if !ok || !include.System || included[include.Name]{
+                continue
+            }
+
+            included[include.Name] = true
+            headers[include.Name] += 1
+            if compareStandards(standard, newest) > 0{
+                newest = standard
+            }
+        }
+    }
+
+    for feature, usage := range(features){
+        if codeLines > 0{
+            usage.PerKloc = float64(usage.Count) * 1000 / float64(codeLines)
+        }
+        features[feature] = usage
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["CodeLines"] = codeLines
+    result["Features"] = features
+    allocations := features["smart_pointer_allocations"].Count
+    result["SmartPointerRatio"] = util.Ratio(allocations, allocations + features[FeatureNew].Count)
+    result["ModernHeaders"] = headers
+    result["NewestHeaderStandard"] = newest
+
+    return result
+}
+
+// Count the uses of the language features in the code tokens.
+func countFeatures(tokens []Token) map[string]int{
+    counts := make(map[string]int)
+    features := make(map[string]string)
+    for feature, names := range(featureIdentifiers){
+        for _, name := range(names){
+            features[name] = feature
+        }
+    }
+
+    for i, token := range(tokens){
+        prev := ""
+        if i > 0{
+            prev = tokens[i - 1].Text
+        }
+
+        next := ""
+        if i + 1 < len(tokens){
+            next = tokens[i + 1].Text
+        }
+
+        if token.Kind == Punct{
+            if token.Text == "[" && isLambda(tokens, i){
+                counts[FeatureLambdas] += 1
+            }
+            continue
+        }
+
+        if token.Kind != Identifier{
+            continue
+        }
+
+        if feature, ok := features[token.Text]; ok{
+            counts[feature] += 1
+        }
+
+        switch token.Text{
+        case "new":
+            if prev != "operator"{
+                counts[FeatureNew] += 1
+            }
+        case "delete":
+            if prev != "operator" && prev != "="{
+                counts[FeatureDelete] += 1
+            }
+        case "for":
+            if next == "(" && isRangeFor(tokens, i + 1){
+                counts[FeatureRangeFor] += 1
+            }
+        case "enum":
+            if next == "class" || next == "struct"{
+                counts[FeatureEnumClass] += 1
+            }
+        case "move", "forward":
+            if prev == "::" && i > 1 && tokens[i - 2].Text == "std"{
+                counts[FeatureMove] += 1
+            }
+        }
+    }
+
+    return counts
+}
+
+// Test if the bracket at index i introduces a lambda, that is
+// it does not follow an expression and the matching bracket is
+// followed by parameters, a body or lambda specifiers.
+func isLambda(tokens []Token, i int) bool{
+    if i > 0{
+        prev := tokens[i - 1]
+        if prev.Text == "[" || prev.Text == ")" || prev.Text == "]" || prev.Kind == Number || prev.Kind == String ||
+                (prev.Kind == Identifier && !IsKeyword(prev.Text)){
+            return false
+        }
+    }
+
+    if i + 1 < len(tokens) && tokens[i + 1].Text == "["{
+        return false
+    }
+
+    // find the matching bracket
+    depth := 0
+    for j := i; j < len(tokens); j++{
+        switch tokens[j].Text{
+        case "[":
+            depth += 1
+        case "]":
+            depth -= 1
+            if depth == 0{
+                if j + 1 == len(tokens){
+                    return false
+                }
+
+                next := tokens[j + 1].Text
+                return next == "(" || next == "{" || next == "<" || next == "mutable" || next == "->"
+            }
+        }
+    }
+
+    return false
+}
+
+// Test if the parenthesis at index i starts the head of
+// a range-based for loop, containing a single colon.
+func isRangeFor(tokens []Token, i int) bool{
+    depth := 0
+    for j := i; j < len(tokens); j++{
+        switch tokens[j].Text{
+        case "(", "[", "{":
+            depth += 1
+        case ")", "]", "}":
+            depth -= 1
+            if depth == 0{
+                return false
+            }
+        case ";":
+            return false
+        case ":":
+            if depth == 1{
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+// Count the standards given by the -std flags of the commands,
+// normalised to the form c++NN. Returns a map from standards
+// to the number of commands using them.
+func StandardFlags(commands []CompileCommand) map[string]int{
+    flags := make(map[string]int)
+    for _, command := range(commands){
+        standard := ""
+        for _, arg := range(command.Args()){
+            match := standardRegexp.FindStringSubmatch(arg)
+            if match == nil{
+                continue
+            }
+
+            version := match[2]
+            if alias, ok := standardAliases[version]; ok{
+                version = alias
+            }
+            standard = "c++" + version
+        }
+
+        if standard != ""{
+            flags[standard] += 1
+        }
+    }
+
+    return flags
+}
+
+// Return the standard used by most commands, preferring
+// the newer standard, or an empty string for no flags.
+func inferStandard(flags map[string]int) string{
+    best := ""
+    for standard, count := range(flags){
+        if best == "" || count > flags[best] || (count == flags[best] && compareStandards(standard, best) > 0){
+            best = standard
+        }
+    }
+
+    return best
+}
+
+// Compare the standards a and b by their publication,
+// where the empty string precedes all standards.
+// Returns a negative number if a is older than b,
+// 0 if they are equal and a positive number else.
+func compareStandards(a, b string) int{
+    return standardIndex(a) - standardIndex(b)
+}
+
+// Return the position of the standard in the list of
+// standards, -1 for the empty string and unknown standards.
+func standardIndex(standard string) int{
+    for i, s := range(standards){
+        if s == strings.ToLower(standard){
+            return i
+        }
+    }
+
+    return -1
+}
@@ -0,0 +1,73 @@
+package source
+
+import (
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/lang"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// struct holding a source file of the analysed revision
+type File struct{
+    Path string
+    Header bool
+    Content string
+}
+
+// Load the source files at the analysed head of the
+// repository in path. Binary files are left out.
+func LoadFiles(path string, opts util.Options) ([]File, error){
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    headCommit, err := git.ResolveHead(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    fileIter, err := headCommit.Files()
+
+    if err != nil{
+        return nil, err
+    }
+
+    profile := lang.ForOptions(opts)
+    var files []File
+    err = fileIter.ForEach(func(file *object.File) error{
+        if !git.IsSourceFile(file.Name, opts){
+            return nil
+        }
+
+        binary, err := file.IsBinary()
+
+        if err != nil{
+            return err
+        }
+
+        if binary{
+            util.PrintDebug("skipping binary file " + file.Name, opts)
+            return nil
+        }
+
+        content, err := file.Contents()
+
+        if err != nil{
+            return err
+        }
+
+        files = append(files, File{Path: file.Name, Header: profile.IsHeader(file.Name), Content: content})
+        return nil
+    })
+
+    if err != nil{
+        return nil, err
+    }
+
+    return files, nil
+}
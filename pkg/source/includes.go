@@ -0,0 +1,641 @@
+package source
+
+import (
+    "path"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// ways of resolving includes
+const (
+    ResolveCompileCommands = "compile_commands"  // include paths of the compilation database
+    ResolveHeuristic = "heuristic"               // matching path suffixes in the repository
+)
+
+// kinds of include issues
+const (
+    IncludeDuplicate = "duplicate"
+    IncludeUnused = "unused"
+)
+
+// matches include directives
+var includeRegexp = regexp.MustCompile(`^#\s*(include|include_next|import)\s*([<"])([^>"]+)[>"]`)
+
+// replaces line continuations in directives by spaces
+var continuationReplacer = strings.NewReplacer("\\\r\n", " ", "\\\n", " ")
+
+// keywords of types preceding declared names
+var typeKeywords = []string{
+    "void", "bool", "char", "short", "int", "long", "float", "double",
+    "signed", "unsigned", "auto", "wchar_t", "char8_t", "char16_t", "char32_t",
+}
+
+// struct representing an include directive
+type Include struct{
+    Name string      // included path as written
+    System bool      // included with angle brackets
+    Line int
+    Resolved string  // included file of the repository, empty if external
+    block int        // conditional block containing the directive
+}
+
+// struct describing an include issue found in the text
+type IncludeIssue struct{
+    File string
+    Line int
+    Include string
+    Kind string
+}
+
+// struct holding the include impact of a header
+type HeaderImpact struct{
+    File string
+    Includers int            // files including the header directly
+    TransitiveIncluders int  // files including the header transitively
+    RebuildImpact int        // translation units including the header transitively
+    ClosureSize int          // files included by the header transitively
+    ClosureLines int         // lines of the header and its include closure
+    Cost int                 // rebuild impact times closure lines
+}
+
+// struct holding the include graph of the source files
+type includeGraph struct{
+    files []File
+    index map[string]int     // maps paths to file indices
+    byName map[string][]int  // maps base names to file indices
+    strict bool              // only resolve system includes in the include paths
+    includes [][]Include     // includes of every file
+    edges [][]int            // resolved includes of every file
+    reverse [][]int          // files including every file
+}
+
+// Run the include graph analysis on the source files at the
+// analysed head of the repository in path. Includes are resolved
+// with the include paths of the compilation database in the root
+// of the repository at the analysed head if it has any, falling
+// back to matching path suffixes of the repository files,
+// preferring the closest file. With these include paths,
+// system includes are only resolved in them.
+// Outputs a map with the following fields:
+//   IncludeResolution     string
+//   IncludeCount          int
+//   ResolvedIncludeCount  int
+//   ExternalIncludeCount  int
+//   AvgIncludeClosure     float64
+//   MaxIncludeClosure     int
+//   AvgRebuildImpact      float64
+//   MaxRebuildImpact      int
+//   IncludeCycleCount     int
+//   IncludeCycles         [][]string
+//   DuplicateIncludeCount int
+//   UnusedIncludeCount    int
+//   IncludeIssues         []IncludeIssue
+//   ExpensiveHeaders      []HeaderImpact
+//   Headers               map[string]HeaderImpact
+func RunIncludeAnalysis(path string, opts util.Options) (map[string]interface{}, error){
+    util.PrintDebug("loading source files", opts)
+    files, err := LoadFiles(path, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // load include paths
+    var dirs []string
+    commands, err := LoadCompileCommands(path, opts)
+    if err == nil{
+        dirs = IncludeDirs(commands, path)
+        if len(dirs) == 0{
+            util.PrintDebug("no include directories in the compilation database, resolving includes heuristically", opts)
+        }
+    } else{
+        util.PrintDebug("no compilation database, resolving includes heuristically: " + err.Error(), opts)
+    }
+
+    util.PrintDebug("analysing include graph", opts)
+    return AnalyseIncludes(files, dirs, len(dirs) > 0, opts), nil
+}
+
+// Build the include graph of the files and analyse it.
+// dirs are the slash separated include paths relative to
+// the repository root, compileCommands tells if they were
+// taken from a compilation database, in which case system
+// includes are not resolved by path suffixes. Returns a map with the
+// fields of RunIncludeAnalysis. The closure metrics are averaged
+// over translation units and the rebuild impacts over headers.
+// ExpensiveHeaders lists the opts.TopN headers with the highest cost.
+func AnalyseIncludes(files []File, dirs []string, compileCommands bool, opts util.Options) map[string]interface{}{
+    graph := buildIncludeGraph(files, dirs, compileCommands)
+
+    // count includes
+    includeCount := 0
+    resolved := 0
+    for _, includes := range(graph.includes){
+        includeCount += len(includes)
+        for _, include := range(includes){
+            if include.Resolved != ""{
+                resolved += 1
+            }
+        }
+    }
+
+    // calculate closures and impacts
+    lines := make([]int, len(files))
+    for i, file := range(files){
+        lines[i] = strings.Count(file.Content, "\n")
+    }
+
+    var closures, impacts []float64
+    maxClosure := 0
+    maxImpact := 0
+    headers := make(map[string]HeaderImpact)
+    var ranking []HeaderImpact
+    for i, file := range(files){
+        closure := reachable(graph.edges, i)
+        if !file.Header{
+            closures = append(closures, float64(len(closure)))
+            if len(closure) > maxClosure{
+                maxClosure = len(closure)
+            }
+            continue
+        }
+
+        impact := HeaderImpact{File: file.Path, Includers: len(graph.reverse[i]), ClosureSize: len(closure), ClosureLines: lines[i]}
+        for _, j := range(closure){
+            impact.ClosureLines += lines[j]
+        }
+
+        for _, j := range(reachable(graph.reverse, i)){
+            impact.TransitiveIncluders += 1
+            if !files[j].Header{
+                impact.RebuildImpact += 1
+            }
+        }
+        impact.Cost = impact.RebuildImpact * impact.ClosureLines
+
+        headers[file.Path] = impact
+        ranking = append(ranking, impact)
+        impacts = append(impacts, float64(impact.RebuildImpact))
+        if impact.RebuildImpact > maxImpact{
+            maxImpact = impact.RebuildImpact
+        }
+    }
+
+    sort.Slice(ranking, func(i, j int) bool{
+        if ranking[i].Cost != ranking[j].Cost{
+            return ranking[i].Cost > ranking[j].Cost
+        }
+
+        return ranking[i].File < ranking[j].File
+    })
+
+    if opts.TopN > 0 && len(ranking) > opts.TopN{
+        ranking = ranking[:opts.TopN]
+    }
+
+    // find cycles and issues
+    cycles := includeCycles(graph)
+    issues := includeIssues(graph)
+    duplicates := 0
+    for _, issue := range(issues){
+        if issue.Kind == IncludeDuplicate{
+            duplicates += 1
+        }
+    }
+
+    resolution := ResolveHeuristic
+    if compileCommands{
+        resolution = ResolveCompileCommands
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["IncludeResolution"] = resolution
+    result["IncludeCount"] = includeCount
+    result["ResolvedIncludeCount"] = resolved
+    result["ExternalIncludeCount"] = includeCount - resolved
+    result["AvgIncludeClosure"] = util.Mean(closures)
+    result["MaxIncludeClosure"] = maxClosure
+    result["AvgRebuildImpact"] = util.Mean(impacts)
+    result["MaxRebuildImpact"] = maxImpact
+    result["IncludeCycleCount"] = len(cycles)
+    result["IncludeCycles"] = cycles
+    result["DuplicateIncludeCount"] = duplicates
+    result["UnusedIncludeCount"] = len(issues) - duplicates
+    result["IncludeIssues"] = issues
+    result["ExpensiveHeaders"] = ranking
+    result["Headers"] = headers
+
+    return result
+}
+
+// Parse the includes of the files and resolve them. If strict is
+// set, system includes are only resolved in the include paths dirs.
+func buildIncludeGraph(files []File, dirs []string, strict bool) *includeGraph{
+    graph := &includeGraph{
+        files: files,
+        index: make(map[string]int),
+        byName: make(map[string][]int),
+        strict: strict,
+        includes: make([][]Include, len(files)),
+        edges: make([][]int, len(files)),
+        reverse: make([][]int, len(files)),
+    }
+
+    for i, file := range(files){
+        graph.index[file.Path] = i
+        graph.byName[path.Base(file.Path)] = append(graph.byName[path.Base(file.Path)], i)
+    }
+
+    for i, file := range(files){
+        includes := parseIncludes(file.Content)
+        seen := make(map[int]bool)
+        for k := range(includes){
+            includes[k].Resolved = graph.resolve(file.Path, includes[k], dirs)
+            j, ok := graph.index[includes[k].Resolved]
+            if !ok || seen[j]{
+                continue
+            }
+
+            seen[j] = true
+            graph.edges[i] = append(graph.edges[i], j)
+            graph.reverse[j] = append(graph.reverse[j], i)
+        }
+        graph.includes[i] = includes
+    }
+
+    return graph
+}
+
+// Resolve an include of the file given by name to a file of the
+// repository. Quoted includes are searched relative to the file
+// first, then in the include paths dirs and finally by matching
+// path suffixes, unless the graph is strict and the include is
+// a system include. Returns an empty string for external includes.
+func (g *includeGraph) resolve(name string, include Include, dirs []string) string{
+    var candidates []string
+    if !include.System{
+        candidates = append(candidates, path.Join(path.Dir(name), include.Name))
+    }
+
+    for _, dir := range(dirs){
+        candidates = append(candidates, path.Join(dir, include.Name))
+    }
+
+    for _, candidate := range(candidates){
+        if _, ok := g.index[candidate]; ok{
+            return candidate
+        }
+    }
+
+    if g.strict && include.System{
+        return ""
+    }
+
+    // match path suffixes, preferring the closest file
+    suffix := "/" + path.Clean(include.Name)
+    best := ""
+    bestShared := -1
+    for _, i := range(g.byName[path.Base(include.Name)]){
+        file := g.files[i]
+        if file.Path != path.Clean(include.Name) && !strings.HasSuffix(file.Path, suffix){
+            continue
+        }
+
+        shared := sharedDirs(name, file.Path)
+        if shared > bestShared || (shared == bestShared && file.Path < best){
+            best = file.Path
+            bestShared = shared
+        }
+    }
+
+    return best
+}
+
+// Parse the include directives of the source src.
+// Includes in the same conditional block get the same block id.
+func parseIncludes(src string) []Include{
+    var includes []Include
+    blocks := []int{0}
+    next := 1
+    for _, token := range(Tokenize(src)){
+        if token.Kind != Directive{
+            continue
+        }
+
+        switch parseDirective(token).name{
+        case "if", "ifdef", "ifndef":
+            blocks = append(blocks, next)
+            next += 1
+        case "elif", "elifdef", "elifndef", "else":
+            blocks[len(blocks) - 1] = next
+            next += 1
+        case "endif":
+            if len(blocks) > 1{
+                blocks = blocks[:len(blocks) - 1]
+            }
+        }
+
+        text := strings.Join(strings.Fields(continuationReplacer.Replace(token.Text)), " ")
+        match := includeRegexp.FindStringSubmatch(text)
+        if match == nil{
+            continue
+        }
+
+        includes = append(includes, Include{
+            Name: strings.TrimSpace(match[3]),
+            System: match[2] == "<",
+            Line: token.Line,
+            block: blocks[len(blocks) - 1],
+        })
+    }
+
+    return includes
+}
+
+// Return the nodes reachable from start in the graph given
+// by its adjacency lists, excluding start.
+func reachable(edges [][]int, start int) []int{
+    var result []int
+    visited := map[int]bool{start: true}
+    stack := append([]int(nil), edges[start]...)
+    for len(stack) > 0{
+        node := stack[len(stack) - 1]
+        stack = stack[:len(stack) - 1]
+        if visited[node]{
+            continue
+        }
+
+        visited[node] = true
+        result = append(result, node)
+        stack = append(stack, edges[node]...)
+    }
+
+    return result
+}
+
+// Find the include cycles of the graph as its strongly
+// connected components with more than one file or a self
+// include. Returns the sorted paths of every cycle.
+func includeCycles(g *includeGraph) [][]string{
+    // tarjan's algorithm
+    n := len(g.files)
+    indices := make([]int, n)
+    lowlinks := make([]int, n)
+    onStack := make([]bool, n)
+    for i := range(indices){
+        indices[i] = -1
+    }
+
+    var stack []int
+    var cycles [][]string
+    index := 0
+    var connect func(v int)
+    connect = func(v int){
+        indices[v] = index
+        lowlinks[v] = index
+        index += 1
+        stack = append(stack, v)
+        onStack[v] = true
+        selfInclude := false
+        for _, w := range(g.edges[v]){
+            if w == v{
+                selfInclude = true
+            }
+
+            if indices[w] < 0{
+                connect(w)
+                if lowlinks[w] < lowlinks[v]{
+                    lowlinks[v] = lowlinks[w]
+                }
+            } else if onStack[w] && indices[w] < lowlinks[v]{
+                lowlinks[v] = indices[w]
+            }
+        }
+
+        if lowlinks[v] != indices[v]{
+            return
+        }
+
+        // pop the component
+        var component []string
+        for{
+            w := stack[len(stack) - 1]
+            stack = stack[:len(stack) - 1]
+            onStack[w] = false
+            component = append(component, g.files[w].Path)
+            if w == v{
+                break
+            }
+        }
+
+        if len(component) > 1 || selfInclude{
+            sort.Strings(component)
+            cycles = append(cycles, component)
+        }
+    }
+
+    for v := 0; v < n; v++{
+        if indices[v] < 0{
+            connect(v)
+        }
+    }
+
+    sort.Slice(cycles, func(i, j int) bool{
+        return cycles[i][0] < cycles[j][0]
+    })
+
+    return cycles
+}
+
+// Find duplicate includes in the same conditional block and
+// includes of repository headers of which the including file
+// uses none of the declared names, including the names declared
+// by the headers they include in turn.
+func includeIssues(g *includeGraph) []IncludeIssue{
+    var issues []IncludeIssue
+
+    // collect the declared names of headers
+    declared := make([]map[string]bool, len(g.files))
+    exports := make(map[int]map[string]bool)
+    exported := func(i int) map[string]bool{
+        if names, ok := exports[i]; ok{
+            return names
+        }
+
+        names := make(map[string]bool)
+        exports[i] = names
+        for _, j := range(append([]int{i}, reachable(g.edges, i)...)){
+            if declared[j] == nil{
+                declared[j] = declaredNames(g.files[j].Content)
+            }
+
+            for name := range(declared[j]){
+                names[name] = true
+            }
+        }
+
+        return names
+    }
+
+    for i, file := range(g.files){
+        // find duplicates
+        for k, include := range(g.includes[i]){
+            if containsInclude(g.includes[i][:k], include){
+                issues = append(issues, IncludeIssue{File: file.Path, Line: include.Line, Include: include.Name, Kind: IncludeDuplicate})
+            }
+        }
+
+        // find unused repository headers
+        var used map[string]bool
+        checked := make(map[int]bool)
+        for _, include := range(g.includes[i]){
+            j, ok := g.index[include.Resolved]
+            if !ok || j == i || !g.files[j].Header || checked[j]{
+                continue
+            }
+            checked[j] = true
+
+            names := exported(j)
+            if len(names) == 0{
+                continue
+            }
+
+            if used == nil{
+                used = usedNames(file.Content)
+            }
+
+            unused := true
+            for name := range(names){
+                if used[name]{
+                    unused = false
+                    break
+                }
+            }
+
+            if unused{
+                issues = append(issues, IncludeIssue{File: file.Path, Line: include.Line, Include: include.Name, Kind: IncludeUnused})
+            }
+        }
+    }
+
+    return issues
+}
+
+// Test if includes contains an include of the
+// same file as include in the same block.
+func containsInclude(includes []Include, include Include) bool{
+    for _, other := range(includes){
+        if other.block == include.block && other.target() == include.target(){
+            return true
+        }
+    }
+
+    return false
+}
+
+// Return the resolved file of the include,
+// or the included path for external includes.
+func (i Include) target() string{
+    if i.Resolved != ""{
+        return i.Resolved
+    }
+
+    return i.Name
+}
+
+// Collect the names declared by the source src: macros,
+// types, namespaces, functions and variables.
+// Names are found by the tokens around identifiers,
+// so declarations inside function bodies are included.
+func declaredNames(src string) map[string]bool{
+    names := make(map[string]bool)
+    tokens := Tokenize(src)
+    for _, token := range(tokens){
+        if token.Kind == Directive{
+            d := parseDirective(token)
+            if d.name == "define" && len(d.args) > 0 && d.args[0].Kind == Identifier{
+                names[d.args[0].Text] = true
+            }
+        }
+    }
+
+    code := CodeTokens(tokens)
+    for i, token := range(code){
+        if token.Kind != Identifier || IsKeyword(token.Text){
+            continue
+        }
+
+        prev := ""
+        if i > 0{
+            prev = code[i - 1].Text
+        }
+
+        next := ""
+        if i + 1 < len(code){
+            next = code[i + 1].Text
+        }
+
+        switch{
+        case prev == "class" || prev == "struct" || prev == "union" || prev == "enum" || prev == "namespace" || prev == "concept":
+            // type and namespace names
+            names[token.Text] = true
+        case prev == "using" && next == "=":
+            // alias declarations
+            names[token.Text] = true
+        case next == "(" || next == ";" || next == "=" || next == "[" || next == "," || next == "{":
+            // functions, variables and enumerators after a type
+            if i > 0 && ((code[i - 1].Kind == Identifier && (!IsKeyword(prev) || util.ContainsString(typeKeywords, prev))) ||
+                    prev == "*" || prev == "&" || prev == ">" || prev == "~"){
+                names[token.Text] = true
+            } else if (prev == "{" || prev == ",") && (next == "," || next == "=" || next == "}"){
+                names[token.Text] = true
+            }
+        case next == "}" && (prev == "{" || prev == ","):
+            // last enumerators
+            names[token.Text] = true
+        }
+    }
+
+    return names
+}
+
+// Collect the identifiers used in the code and
+// the directives of the source src.
+func usedNames(src string) map[string]bool{
+    names := make(map[string]bool)
+    for _, token := range(Tokenize(src)){
+        switch token.Kind{
+        case Identifier:
+            names[token.Text] = true
+        case Directive:
+            d := parseDirective(token)
+            if d.name == "include" || d.name == "include_next" || d.name == "import"{
+                continue
+            }
+
+            for _, arg := range(d.args){
+                if arg.Kind == Identifier{
+                    names[arg.Text] = true
+                }
+            }
+        }
+    }
+
+    return names
+}
+
+// Count the leading directories shared by the paths a and b.
+func sharedDirs(a, b string) int{
+    dirsA := strings.Split(path.Dir(a), "/")
+    dirsB := strings.Split(path.Dir(b), "/")
+    shared := 0
+    for shared < len(dirsA) && shared < len(dirsB) && dirsA[shared] == dirsB[shared]{
+        shared += 1
+    }
+
+    return shared
+}
@@ -0,0 +1,280 @@
+package source
+
+import (
+    "strings"
+)
+
+// kinds of tokens
+const (
+    Identifier = iota  // identifiers and keywords
+    Number
+    String             // string literals including raw strings
+    Char               // character literals
+    Punct              // operators and punctuation
+    Comment
+    Directive          // complete preprocessor directive
+)
+
+// struct representing a token of a c or cpp file
+type Token struct{
+    Kind int
+    Text string
+    Line int     // line of the first character, starting at 1
+    EndLine int  // line of the last character
+}
+
+// multi-character operators ordered by decreasing length
+var operators = []string{
+    "<<=", ">>=", "...", "->*", "<=>",
+    "::", "->", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+    "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", ".*", "##",
+}
+
+// keywords of c and cpp
+var keywords = map[string]bool{
+    "alignas": true, "alignof": true, "and": true, "asm": true, "auto": true,
+    "bool": true, "break": true, "case": true, "catch": true, "char": true,
+    "char8_t": true, "char16_t": true, "char32_t": true, "class": true,
+    "co_await": true, "co_return": true, "co_yield": true, "concept": true,
+    "const": true, "consteval": true, "constexpr": true, "constinit": true,
+    "const_cast": true, "continue": true, "decltype": true, "default": true,
+    "delete": true, "do": true, "double": true, "dynamic_cast": true,
+    "else": true, "enum": true, "explicit": true, "export": true,
+    "extern": true, "false": true, "float": true, "for": true, "friend": true,
+    "goto": true, "if": true, "inline": true, "int": true, "long": true,
+    "mutable": true, "namespace": true, "new": true, "noexcept": true,
+    "not": true, "nullptr": true, "operator": true, "or": true,
+    "private": true, "protected": true, "public": true, "register": true,
+    "reinterpret_cast": true, "requires": true, "restrict": true,
+    "return": true, "short": true, "signed": true, "sizeof": true,
+    "static": true, "static_assert": true, "static_cast": true,
+    "struct": true, "switch": true, "template": true, "this": true,
+    "thread_local": true, "throw": true, "true": true, "try": true,
+    "typedef": true, "typeid": true, "typename": true, "union": true,
+    "unsigned": true, "using": true, "virtual": true, "void": true,
+    "volatile": true, "wchar_t": true, "while": true,
+}
+
+// Test if the identifier s is a c or cpp keyword.
+func IsKeyword(s string) bool{
+    return keywords[s]
+}
+
+// Split the c or cpp source src into tokens.
+// The lexer is tolerant and never fails: unknown characters
+// become punctuation and unterminated literals and comments
+// end at the end of the line or file.
+func Tokenize(src string) []Token{
+    var tokens []Token
+    line := 1
+    lineStart := true  // only whitespace since the last newline
+    i := 0
+    for i < len(src){
+        c := src[i]
+
+        // skip whitespace and line continuations
+        if c == '\n'{
+            line += 1
+            lineStart = true
+            i += 1
+            continue
+        }
+
+        if c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v'{
+            i += 1
+            continue
+        }
+
+        if c == '\\' && i + 1 < len(src) && (src[i + 1] == '\n' || strings.HasPrefix(src[i + 1:], "\r\n")){
+            i = strings.IndexByte(src[i:], '\n') + i + 1
+            line += 1
+            continue
+        }
+
+        start := i
+        startLine := line
+        kind := Punct
+        switch{
+        case c == '/' && i + 1 < len(src) && src[i + 1] == '/':
+            // line comment, possibly continued
+            kind = Comment
+            i, line = scanLine(src, i, line, false)
+        case c == '/' && i + 1 < len(src) && src[i + 1] == '*':
+            // block comment
+            kind = Comment
+            end := strings.Index(src[i + 2:], "*/")
+            if end < 0{
+                i = len(src)
+            } else{
+                i += end + 4
+            }
+            line += strings.Count(src[start:i], "\n")
+        case c == '#' && lineStart:
+            // preprocessor directive up to the end of the line
+            kind = Directive
+            i, line = scanLine(src, i, line, true)
+        case isIdentStart(c):
+            for i < len(src) && isIdentPart(src[i]){
+                i += 1
+            }
+
+            // string and character literals with prefixes
+            prefix := src[start:i]
+            if i < len(src) && (src[i] == '"' || src[i] == '\'') && isLiteralPrefix(prefix){
+                if src[i] == '\''{
+                    kind = Char
+                } else{
+                    kind = String
+                }
+
+                if strings.HasSuffix(prefix, "R") && src[i] == '"'{
+                    i = scanRawString(src, i)
+                } else{
+                    i = scanQuoted(src, i)
+                }
+                line += strings.Count(src[start:i], "\n")
+            } else{
+                kind = Identifier
+            }
+        case isDigit(c) || (c == '.' && i + 1 < len(src) && isDigit(src[i + 1])):
+            // numbers with digit separators, exponents and suffixes
+            kind = Number
+            i += 1
+            for i < len(src){
+                if isIdentPart(src[i]) || src[i] == '.' || (src[i] == '\'' && i + 1 < len(src) && isIdentPart(src[i + 1])){
+                    i += 1
+                } else if (src[i] == '+' || src[i] == '-') && strings.ContainsAny(src[i - 1:i], "eEpP"){
+                    i += 1
+                } else{
+                    break
+                }
+            }
+        case c == '"':
+            kind = String
+            i = scanQuoted(src, i)
+        case c == '\'':
+            kind = Char
+            i = scanQuoted(src, i)
+        default:
+            i += 1
+            for _, op := range(operators){
+                if strings.HasPrefix(src[start:], op){
+                    i = start + len(op)
+                    break
+                }
+            }
+        }
+
+        tokens = append(tokens, Token{Kind: kind, Text: src[start:i], Line: startLine, EndLine: line})
+        lineStart = false
+    }
+
+    return tokens
+}
+
+// Scan from i to the end of the line, following line continuations.
+// Directives stop before line comments and skip block comments.
+// Returns the end index and the line number at the end.
+func scanLine(src string, i, line int, directive bool) (int, int){
+    for i < len(src){
+        switch{
+        case src[i] == '\n':
+            return i, line
+        case src[i] == '\\' && strings.HasPrefix(src[i + 1:], "\n"):
+            i += 2
+            line += 1
+        case src[i] == '\\' && strings.HasPrefix(src[i + 1:], "\r\n"):
+            i += 3
+            line += 1
+        case directive && strings.HasPrefix(src[i:], "//"):
+            return i, line
+        case directive && strings.HasPrefix(src[i:], "/*"):
+            end := strings.Index(src[i + 2:], "*/")
+            if end < 0{
+                return len(src), line + strings.Count(src[i:], "\n")
+            }
+
+            line += strings.Count(src[i:i + end + 4], "\n")
+            i += end + 4
+        default:
+            i += 1
+        }
+    }
+
+    return i, line
+}
+
+// Scan a string or character literal starting at the quote at i.
+// Returns the index after the closing quote.
+func scanQuoted(src string, i int) int{
+    quote := src[i]
+    i += 1
+    for i < len(src){
+        switch src[i]{
+        case '\\':
+            i += 2
+            continue
+        case quote:
+            return i + 1
+        case '\n':
+            return i
+        }
+        i += 1
+    }
+
+    return len(src)
+}
+
+// Scan a raw string literal starting at the quote at i.
+// Returns the index after the closing quote.
+func scanRawString(src string, i int) int{
+    open := strings.IndexByte(src[i:], '(')
+    if open < 0{
+        return scanQuoted(src, i)
+    }
+
+    delimiter := ")" + src[i + 1:i + open] + "\""
+    end := strings.Index(src[i + open:], delimiter)
+    if end < 0{
+        return len(src)
+    }
+
+    return i + open + end + len(delimiter)
+}
+
+// Test if s is a prefix of string or character literals.
+func isLiteralPrefix(s string) bool{
+    switch s{
+    case "L", "u", "U", "u8", "R", "LR", "uR", "UR", "u8R":
+        return true
+    }
+
+    return false
+}
+
+// Test if c can start an identifier.
+func isIdentStart(c byte) bool{
+    return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// Test if c can continue an identifier.
+func isIdentPart(c byte) bool{
+    return isIdentStart(c) || isDigit(c)
+}
+
+// Test if c is a decimal digit.
+func isDigit(c byte) bool{
+    return c >= '0' && c <= '9'
+}
+
+// Return the tokens without comments and directives.
+func CodeTokens(tokens []Token) []Token{
+    code := make([]Token, 0, len(tokens))
+    for _, token := range(tokens){
+        if token.Kind != Comment && token.Kind != Directive{
+            code = append(code, token)
+        }
+    }
+
+    return code
+}
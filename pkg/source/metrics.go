@@ -0,0 +1,499 @@
+package source
+
+import (
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/gct"
+    "github.com/j-bhm/CppGitMining/pkg/sct"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// kinds of blocks opened by braces
+const (
+    blockOther = iota     // initialisers and blocks inside functions
+    blockScope            // namespaces, classes and linkage specifications
+    blockFunction         // function bodies
+    blockInitialiser      // brace initialisers of member initialiser lists
+)
+
+// keywords and operators adding a decision to the cyclomatic complexity
+var decisionKeywords = []string{"if", "for", "while", "case", "catch", "and", "or"}
+var decisionOperators = []string{"&&", "||", "?"}
+
+// keywords starting a logical line without a semicolon
+var statementKeywords = []string{"if", "for", "while", "switch", "try", "catch", "case"}
+
+// keywords followed by a parenthesis that are no function names
+var nonFunctionKeywords = []string{
+    "sizeof", "alignof", "alignas", "decltype", "noexcept", "throw",
+    "requires", "__attribute__", "__declspec", "static_assert", "return",
+}
+
+// struct describing a function definition
+type Function struct{
+    File string
+    Name string
+    Line int          // line of the function name
+    Lines int         // lines from the name to the closing brace
+    Complexity int    // approximate cyclomatic complexity
+    NestingDepth int  // maximum block nesting inside the body
+}
+
+// struct holding the metrics of a source file
+type FileMetrics struct{
+    Header bool
+    PhysicalLines int
+    CodeLines int       // lines containing code or directives
+    CommentLines int    // lines containing only comments
+    BlankLines int
+    LogicalLines int    // statements, function definitions and directives
+    CommentDensity float64
+    FunctionCount int
+    Complexity int      // sum of the complexities of the functions
+    MaxComplexity int
+    MaxNestingDepth int
+}
+
+// Run the source metrics analysis on the source files at
+// the analysed head of the repository in path. Uses the
+// outputs of previous sct and gct runs if available to
+// normalise the coupling degrees by the size of the code.
+// Outputs a map with the following fields:
+//   FileCount        int
+//   PhysicalLines    int
+//   CodeLines        int
+//   CommentLines     int
+//   BlankLines       int
+//   LogicalLines     int
+//   CommentDensity   float64
+//   HeaderCodeLines  int
+//   SourceCodeLines  int
+//   HeaderCodeRatio  float64
+//   FunctionCount    int
+//   AvgFunctionLines float64
+//   AvgComplexity    float64
+//   MedianComplexity float64
+//   MaxComplexity    int
+//   MaxNestingDepth  int
+//   ComplexFunctions []Function
+//   Files            map[string]FileMetrics
+//   ScdPerKloc       float64 (if sct output is available)
+//   GcdPerKloc       float64 (if gct output is available)
+func RunSourceAnalysis(path string, opts util.Options) (map[string]interface{}, error){
+    util.PrintDebug("loading source files", opts)
+    files, err := LoadFiles(path, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    util.PrintDebug("calculating source metrics", opts)
+    result := AnalyseSourceFiles(files, opts)
+
+    // normalise coupling degrees of previous runs
+    kloc := float64(result["CodeLines"].(int)) / 1000
+    if kloc == 0{
+        return result, nil
+    }
+
+    sctJson, err := sct.LoadSctOutput(path, opts)
+    if err == nil{
+        sctJson, err = sct.FilterSctJson(sctJson, path, opts)
+    }
+
+    if err == nil{
+        result["ScdPerKloc"] = sumDegrees(sct.SctNodeDegrees(sctJson)) / kloc
+    } else{
+        util.PrintDebug("no sct output for source metrics: " + err.Error(), opts)
+    }
+
+    gctJson, err := gct.LoadFilteredGctJson(path, opts)
+    if err == nil{
+        result["GcdPerKloc"] = sumDegrees(gct.GctNodeDegrees(gctJson)) / kloc
+    } else{
+        util.PrintDebug("no gct output for source metrics: " + err.Error(), opts)
+    }
+
+    return result, nil
+}
+
+// Calculate the sum of the cyclomatic complexities of the
+// functions of every source file at the analysed head of
+// the repository in path. Returns a map from paths to sums.
+func FileComplexities(path string, opts util.Options) (map[string]float64, error){
+    files, err := LoadFiles(path, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    complexities := make(map[string]float64)
+    for _, file := range(files){
+        metrics, _ := AnalyseFile(file)
+        complexities[filepath.ToSlash(file.Path)] = float64(metrics.Complexity)
+    }
+
+    return complexities, nil
+}
+
+// Calculate the metrics of the files and aggregate them.
+// Returns a map with the fields of RunSourceAnalysis without
+// the coupling degrees. ComplexFunctions lists the opts.TopN
+// functions with the highest complexity.
+func AnalyseSourceFiles(files []File, opts util.Options) map[string]interface{}{
+    result := make(map[string]interface{})
+    metrics := make(map[string]FileMetrics)
+    var functions []Function
+    var total FileMetrics
+    headerCode := 0
+    for _, file := range(files){
+        m, fileFunctions := AnalyseFile(file)
+        metrics[file.Path] = m
+        functions = append(functions, fileFunctions...)
+
+        total.PhysicalLines += m.PhysicalLines
+        total.CodeLines += m.CodeLines
+        total.CommentLines += m.CommentLines
+        total.BlankLines += m.BlankLines
+        total.LogicalLines += m.LogicalLines
+        total.FunctionCount += m.FunctionCount
+        if m.MaxComplexity > total.MaxComplexity{
+            total.MaxComplexity = m.MaxComplexity
+        }
+
+        if m.MaxNestingDepth > total.MaxNestingDepth{
+            total.MaxNestingDepth = m.MaxNestingDepth
+        }
+
+        if m.Header{
+            headerCode += m.CodeLines
+        }
+    }
+
+    // aggregate function metrics
+    complexities := make([]float64, len(functions))
+    functionLines := 0
+    for i, f := range(functions){
+        complexities[i] = float64(f.Complexity)
+        functionLines += f.Lines
+    }
+
+    sort.SliceStable(functions, func(i, j int) bool{
+        if functions[i].Complexity != functions[j].Complexity{
+            return functions[i].Complexity > functions[j].Complexity
+        }
+
+        if functions[i].File != functions[j].File{
+            return functions[i].File < functions[j].File
+        }
+
+        return functions[i].Line < functions[j].Line
+    })
+
+    if opts.TopN > 0 && len(functions) > opts.TopN{
+        functions = functions[:opts.TopN]
+    }
+
+    // set result values
+    result["FileCount"] = len(files)
+    result["PhysicalLines"] = total.PhysicalLines
+    result["CodeLines"] = total.CodeLines
+    result["CommentLines"] = total.CommentLines
+    result["BlankLines"] = total.BlankLines
+    result["LogicalLines"] = total.LogicalLines
+    result["CommentDensity"] = util.Ratio(total.CommentLines, total.CodeLines + total.CommentLines)
+    result["HeaderCodeLines"] = headerCode
+    result["SourceCodeLines"] = total.CodeLines - headerCode
+    result["HeaderCodeRatio"] = util.Ratio(headerCode, total.CodeLines)
+    result["FunctionCount"] = total.FunctionCount
+    result["AvgFunctionLines"] = util.Ratio(functionLines, len(complexities))
+    result["AvgComplexity"] = util.Mean(complexities)
+    result["MedianComplexity"] = util.Median(complexities)
+    result["MaxComplexity"] = total.MaxComplexity
+    result["MaxNestingDepth"] = total.MaxNestingDepth
+    result["ComplexFunctions"] = functions
+    result["Files"] = metrics
+
+    return result
+}
+
+// Calculate the metrics of a single file and return them
+// with the function definitions found in the file.
+// Functions are recognised by a name followed by a parameter
+// list and a body outside of other functions. The cyclomatic
+// complexity counts the decisions of the body, ignoring the
+// preprocessor, so conditional code of all branches is counted.
+func AnalyseFile(file File) (FileMetrics, []Function){
+    var metrics FileMetrics
+    metrics.Header = file.Header
+    tokens := Tokenize(file.Content)
+
+    // classify the physical lines
+    metrics.PhysicalLines = strings.Count(file.Content, "\n")
+    if file.Content != "" && !strings.HasSuffix(file.Content, "\n"){
+        metrics.PhysicalLines += 1
+    }
+
+    code := make([]bool, metrics.PhysicalLines + 2)
+    comment := make([]bool, metrics.PhysicalLines + 2)
+    for _, token := range(tokens){
+        lines := code
+        if token.Kind == Comment{
+            lines = comment
+        }
+
+        for l := token.Line; l <= token.EndLine && l < len(lines); l++{
+            lines[l] = true
+        }
+
+        if token.Kind == Directive{
+            metrics.LogicalLines += 1
+        }
+    }
+
+    for l := 1; l <= metrics.PhysicalLines; l++{
+        switch{
+        case code[l]:
+            metrics.CodeLines += 1
+        case comment[l]:
+            metrics.CommentLines += 1
+        default:
+            metrics.BlankLines += 1
+        }
+    }
+    metrics.CommentDensity = util.Ratio(metrics.CommentLines, metrics.CodeLines + metrics.CommentLines)
+
+    // find functions and statements
+    logical, functions := parseFunctions(file.Path, firstBranchTokens(tokens))
+    metrics.LogicalLines += logical
+    metrics.FunctionCount = len(functions)
+    for _, f := range(functions){
+        metrics.Complexity += f.Complexity
+        if f.Complexity > metrics.MaxComplexity{
+            metrics.MaxComplexity = f.Complexity
+        }
+
+        if f.NestingDepth > metrics.MaxNestingDepth{
+            metrics.MaxNestingDepth = f.NestingDepth
+        }
+    }
+
+    return metrics, functions
+}
+
+// Return the code tokens in the first branch of every
+// preprocessor conditional, leaving out the #elif and #else
+// branches, so that alternative function heads or braces
+// in these branches do not break the brace matching.
+func firstBranchTokens(tokens []Token) []Token{
+    code := make([]Token, 0, len(tokens))
+    var skipped []bool  // per open conditional, if in a later branch
+    skipping := 0
+    for _, token := range(tokens){
+        if token.Kind == Directive{
+            switch parseDirective(token).name{
+            case "if", "ifdef", "ifndef":
+                skipped = append(skipped, false)
+            case "elif", "elifdef", "elifndef", "else":
+                if len(skipped) > 0 && !skipped[len(skipped) - 1]{
+                    skipped[len(skipped) - 1] = true
+                    skipping += 1
+                }
+            case "endif":
+                if len(skipped) > 0{
+                    if skipped[len(skipped) - 1]{
+                        skipping -= 1
+                    }
+                    skipped = skipped[:len(skipped) - 1]
+                }
+            }
+            continue
+        }
+
+        if token.Kind != Comment && skipping == 0{
+            code = append(code, token)
+        }
+    }
+
+    return code
+}
+
+// Find the function definitions in the code tokens of the
+// file given by path. Returns the number of logical lines
+// and the functions.
+func parseFunctions(path string, tokens []Token) (int, []Function){
+    var functions []Function
+    var stack []int
+    var current *Function
+    body := 0          // stack size inside the current function body
+    parens := 0
+    logical := 0
+
+    // state of the declaration outside of functions
+    candidate := -1    // index of the candidate function name
+    initList := false  // inside a member initialiser list
+    scope := ""        // keyword of a class or namespace declaration
+    reset := func(){
+        candidate = -1
+        initList = false
+        scope = ""
+    }
+
+    for i, token := range(tokens){
+        text := token.Text
+        prev := ""
+        if i > 0{
+            prev = tokens[i - 1].Text
+        }
+
+        if token.Kind == Identifier{
+            // count statements and decisions
+            if util.ContainsString(statementKeywords, text) ||
+                    (text == "else" && (i + 1 == len(tokens) || tokens[i + 1].Text != "if")) ||
+                    (text == "default" && i + 1 < len(tokens) && tokens[i + 1].Text == ":"){
+                logical += 1
+            }
+
+            if current != nil{
+                if util.ContainsString(decisionKeywords, text){
+                    current.Complexity += 1
+                }
+            } else if parens == 0 && prev != "<" && prev != ","{
+                switch text{
+                case "class", "namespace":
+                    scope = text
+                case "struct", "union", "enum":
+                    if candidate < 0 && scope == ""{
+                        scope = text
+                    }
+                }
+            }
+            continue
+        }
+
+        if token.Kind != Punct{
+            continue
+        }
+
+        switch text{
+        case "(":
+            if current == nil && parens == 0 && !initList{
+                if i > 0 && tokens[i - 1].Kind == Identifier && !util.ContainsString(nonFunctionKeywords, prev){
+                    candidate = i - 1
+                } else if i > 1 && tokens[i - 2].Text == "operator"{
+                    candidate = i - 2
+                }
+            }
+            parens += 1
+        case ")":
+            if parens > 0{
+                parens -= 1
+            }
+        case ":":
+            if current == nil && parens == 0 && candidate >= 0{
+                initList = true
+            }
+        case "=":
+            if current == nil && parens == 0 && prev != "operator"{
+                candidate = -1
+                initList = false
+            }
+        case ";":
+            if parens == 0{
+                logical += 1
+                if current == nil{
+                    reset()
+                }
+            }
+        case "{":
+            switch{
+            case current != nil:
+                stack = append(stack, blockOther)
+                if len(stack) - body > current.NestingDepth{
+                    current.NestingDepth = len(stack) - body
+                }
+            case initList && tokens[i - 1].Kind == Identifier:
+                stack = append(stack, blockInitialiser)
+            case candidate >= 0 && scope != "class" && scope != "namespace":
+                current = &Function{
+                    File: path,
+                    Name: functionName(tokens, candidate),
+                    Line: tokens[candidate].Line,
+                    Complexity: 1,
+                }
+                logical += 1
+                stack = append(stack, blockFunction)
+                body = len(stack)
+                parens = 0
+                reset()
+            case scope != "":
+                stack = append(stack, blockScope)
+                reset()
+            default:
+                stack = append(stack, blockOther)
+                reset()
+            }
+        case "}":
+            if len(stack) == 0{
+                continue
+            }
+
+            kind := stack[len(stack) - 1]
+            stack = stack[:len(stack) - 1]
+            if kind == blockFunction && current != nil{
+                current.Lines = token.EndLine - current.Line + 1
+                functions = append(functions, *current)
+                current = nil
+                reset()
+            } else if kind != blockInitialiser && current == nil{
+                reset()
+            }
+        default:
+            if current != nil && util.ContainsString(decisionOperators, text){
+                current.Complexity += 1
+            }
+        }
+    }
+
+    // close functions left open by unbalanced braces
+    if current != nil && len(tokens) > 0{
+        current.Lines = tokens[len(tokens) - 1].EndLine - current.Line + 1
+        functions = append(functions, *current)
+    }
+
+    return logical, functions
+}
+
+// Return the qualified name of the function whose
+// name is the token at index i, including scopes,
+// destructor tildes and operator symbols.
+func functionName(tokens []Token, i int) string{
+    name := tokens[i].Text
+    if name == "operator" && i + 1 < len(tokens) && tokens[i + 1].Kind == Punct{
+        name += tokens[i + 1].Text
+    }
+
+    if i > 0 && tokens[i - 1].Text == "~"{
+        name = "~" + name
+        i -= 1
+    }
+
+    for i > 1 && tokens[i - 1].Text == "::" && tokens[i - 2].Kind == Identifier{
+        name = tokens[i - 2].Text + "::" + name
+        i -= 2
+    }
+
+    return name
+}
+
+// Calculate the sum of the degrees.
+func sumDegrees(degrees map[string]float64) float64{
+    sum := 0.0
+    for _, degree := range(degrees){
+        sum += degree
+    }
+
+    return sum
+}
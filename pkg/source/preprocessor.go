@@ -0,0 +1,440 @@
+package source
+
+import (
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/j-bhm/CppGitMining/pkg/gct"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+)
+
+// identifiers of conditions that are no configuration macros
+var conditionKeywords = []string{"defined", "true", "false"}
+
+// preprocessor functions whose arguments are no configuration macros
+var conditionFunctions = []string{
+    "__has_include", "__has_include_next", "__has_attribute", "__has_cpp_attribute",
+    "__has_builtin", "__has_feature", "__has_extension", "__has_c_attribute",
+}
+
+// struct holding a parsed preprocessor directive
+type directive struct{
+    name string
+    args []Token
+}
+
+// struct holding the preprocessor metrics of a source file
+type PreprocessorMetrics struct{
+    ConditionalDirectives int  // #if, #ifdef, #ifndef, #elif, #else and #endif
+    ConditionalBlocks int      // #if, #ifdef and #ifndef
+    MaxNesting int             // maximum nesting of conditional blocks
+    ConditionalCodeShare float64
+    Macros []string            // configuration macros referenced in conditions
+}
+
+// struct describing the scattering of a configuration macro
+type MacroScattering struct{
+    Macro string
+    Conditions int  // conditional directives referencing the macro
+    Files int       // files referencing the macro
+}
+
+// Run the preprocessor variability analysis on the source files
+// at the analysed head of the repository in path. Include guards
+// are not counted as conditional blocks. If opts.PreprocessorCoChange
+// is set, the change coupling of files with a share of conditional
+// code of at least opts.IfdefHeavyShare is compared to the other files,
+// using the gct output if available and the native change coupling else.
+// Outputs a map with the following fields:
+//   ConditionalDirectiveCount int
+//   ConditionalBlockCount     int
+//   ConditionalFileCount      int
+//   ConfigMacroCount          int
+//   MaxIfdefNesting           int
+//   AvgIfdefNesting           float64
+//   ConditionalCodeShare      float64
+//   AvgScatteringDegree       float64
+//   AvgTanglingDegree         float64
+//   ScatteredMacros           []MacroScattering
+//   Files                     map[string]PreprocessorMetrics
+//   IfdefCoChange             map[string]interface{} (if enabled)
+func RunPreprocessorAnalysis(path string, opts util.Options) (map[string]interface{}, error){
+    util.PrintDebug("loading source files", opts)
+    files, err := LoadFiles(path, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    util.PrintDebug("analysing preprocessor directives", opts)
+    result := AnalysePreprocessor(files, opts)
+    if !opts.PreprocessorCoChange{
+        return result, nil
+    }
+
+    // load change coupling, falling back to the native
+    // change coupling if no gct output is available
+    var gctJson *gct.GctJson
+    if gct.UsesNativeGct(opts){
+        gctJson, err = gct.NativeGctJson(path, opts)
+    } else{
+        gctJson, err = gct.LoadGctOutput(path, opts)
+        if err != nil{
+            util.PrintDebug("no gct output for preprocessor co-changes: " + err.Error(), opts)
+            gctJson, err = gct.NativeGctJson(path, opts)
+        }
+    }
+
+    if err != nil{
+        return nil, err
+    }
+
+    gctJson, err = gct.FilterGctJson(gctJson, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    metrics := result["Files"].(map[string]PreprocessorMetrics)
+    result["IfdefCoChange"] = AnalyseIfdefCoChange(metrics, gctJson, opts)
+
+    return result, nil
+}
+
+// Calculate the preprocessor metrics of the files and aggregate them.
+// Returns a map with the fields of RunPreprocessorAnalysis without
+// the co-change view. The scattering degree of a macro is the number
+// of conditions referencing it, the tangling degree of a condition the
+// number of macros it references. Files only lists files containing
+// conditional blocks and ScatteredMacros the opts.TopN macros with the
+// highest scattering degree.
+func AnalysePreprocessor(files []File, opts util.Options) map[string]interface{}{
+    metrics := make(map[string]PreprocessorMetrics)
+    conditions := make(map[string]int)
+    macroFiles := make(map[string]int)
+    var tangling []float64
+    var nestings []float64
+    directives := 0
+    blocks := 0
+    maxNesting := 0
+    codeLines := 0
+    conditionalLines := 0
+    for _, file := range(files){
+        m, fileCodeLines, fileConditionalLines, fileTangling := analyseConditionals(file.Content, conditions)
+        codeLines += fileCodeLines
+        conditionalLines += fileConditionalLines
+        tangling = append(tangling, fileTangling...)
+        for _, macro := range(m.Macros){
+            macroFiles[macro] += 1
+        }
+
+        if m.ConditionalBlocks == 0{
+            continue
+        }
+
+        metrics[file.Path] = m
+        directives += m.ConditionalDirectives
+        blocks += m.ConditionalBlocks
+        nestings = append(nestings, float64(m.MaxNesting))
+        if m.MaxNesting > maxNesting{
+            maxNesting = m.MaxNesting
+        }
+    }
+
+    // rank macros by scattering
+    scattering := make([]MacroScattering, 0, len(conditions))
+    degrees := make([]float64, 0, len(conditions))
+    for macro, count := range(conditions){
+        scattering = append(scattering, MacroScattering{Macro: macro, Conditions: count, Files: macroFiles[macro]})
+        degrees = append(degrees, float64(count))
+    }
+
+    sort.Slice(scattering, func(i, j int) bool{
+        if scattering[i].Conditions != scattering[j].Conditions{
+            return scattering[i].Conditions > scattering[j].Conditions
+        }
+
+        return scattering[i].Macro < scattering[j].Macro
+    })
+
+    if opts.TopN > 0 && len(scattering) > opts.TopN{
+        scattering = scattering[:opts.TopN]
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["ConditionalDirectiveCount"] = directives
+    result["ConditionalBlockCount"] = blocks
+    result["ConditionalFileCount"] = len(metrics)
+    result["ConfigMacroCount"] = len(conditions)
+    result["MaxIfdefNesting"] = maxNesting
+    result["AvgIfdefNesting"] = util.Mean(nestings)
+    result["ConditionalCodeShare"] = util.Ratio(conditionalLines, codeLines)
+    result["AvgScatteringDegree"] = util.Mean(degrees)
+    result["AvgTanglingDegree"] = util.Mean(tangling)
+    result["ScatteredMacros"] = scattering
+    result["Files"] = metrics
+
+    return result
+}
+
+// Compare the change coupling of ifdef-heavy files, which have
+// a share of conditional code of at least opts.IfdefHeavyShare,
+// to the other files of the gct graph. metrics maps paths to the
+// preprocessor metrics of files with conditional blocks.
+// Returns a map with the following fields:
+//   HeavyFileCount        int
+//   CoupledHeavyFileCount int
+//   HeavyEdgeShare        float64
+//   AvgHeavyGcd           float64
+//   AvgOtherGcd           float64
+func AnalyseIfdefCoChange(metrics map[string]PreprocessorMetrics, gctJson *gct.GctJson, opts util.Options) map[string]interface{}{
+    heavy := make(map[string]bool)
+    for file, m := range(metrics){
+        if m.ConditionalCodeShare >= opts.IfdefHeavyShare{
+            heavy[file] = true
+        }
+    }
+
+    // split coupling degrees
+    var heavyDegrees, otherDegrees []float64
+    for id, degree := range(gct.GctNodeDegrees(gctJson)){
+        if heavy[filepath.ToSlash(filepath.Clean(id))]{
+            heavyDegrees = append(heavyDegrees, degree)
+        } else{
+            otherDegrees = append(otherDegrees, degree)
+        }
+    }
+
+    // count edges touching heavy files
+    heavyEdges := 0
+    for _, edge := range(gctJson.Edges){
+        if heavy[filepath.ToSlash(filepath.Clean(edge.Start))] || heavy[filepath.ToSlash(filepath.Clean(edge.End))]{
+            heavyEdges += 1
+        }
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["HeavyFileCount"] = len(heavy)
+    result["CoupledHeavyFileCount"] = len(heavyDegrees)
+    result["HeavyEdgeShare"] = util.Ratio(heavyEdges, len(gctJson.Edges))
+    result["AvgHeavyGcd"] = util.Mean(heavyDegrees)
+    result["AvgOtherGcd"] = util.Mean(otherDegrees)
+
+    return result
+}
+
+// Analyse the conditional compilation of the source src.
+// Adds the number of conditions referencing each macro to
+// conditions. Returns the metrics of the file, its number of
+// code lines and conditional code lines and the tangling
+// degrees of its conditions.
+func analyseConditionals(src string, conditions map[string]int) (PreprocessorMetrics, int, int, []float64){
+    var metrics PreprocessorMetrics
+    var tangling []float64
+    tokens := Tokenize(src)
+
+    // find an include guard wrapping the file
+    var parsed []directive
+    for _, token := range(tokens){
+        if token.Kind == Directive{
+            parsed = append(parsed, parseDirective(token))
+        }
+    }
+    guarded := isIncludeGuard(parsed)
+
+    // follow the conditional blocks
+    macros := make(map[string]bool)
+    var codeLines, conditionalLines []bool
+    depth := 0
+    next := 0
+    for _, token := range(tokens){
+        if token.Kind == Comment{
+            continue
+        }
+
+        if token.Kind != Directive{
+            for l := token.Line; l <= token.EndLine; l++{
+                codeLines = setLine(codeLines, l)
+                if depth > 0{
+                    conditionalLines = setLine(conditionalLines, l)
+                }
+            }
+            continue
+        }
+
+        d := parsed[next]
+        next += 1
+
+        // the include guard is the first block of the file
+        if guarded && (next == 1 || next == len(parsed)){
+            continue
+        }
+
+        switch d.name{
+        case "if", "ifdef", "ifndef":
+            metrics.ConditionalBlocks += 1
+            depth += 1
+            if depth > metrics.MaxNesting{
+                metrics.MaxNesting = depth
+            }
+        case "elif", "elifdef", "elifndef":
+        case "else":
+            metrics.ConditionalDirectives += 1
+            continue
+        case "endif":
+            metrics.ConditionalDirectives += 1
+            if depth > 0{
+                depth -= 1
+            }
+            continue
+        default:
+            continue
+        }
+        metrics.ConditionalDirectives += 1
+
+        // collect configuration macros of the condition
+        referenced := conditionMacros(d)
+        for _, macro := range(referenced){
+            conditions[macro] += 1
+            macros[macro] = true
+        }
+
+        if len(referenced) > 0{
+            tangling = append(tangling, float64(len(referenced)))
+        }
+    }
+
+    for macro := range(macros){
+        metrics.Macros = append(metrics.Macros, macro)
+    }
+    sort.Strings(metrics.Macros)
+
+    code := countLines(codeLines)
+    conditional := countLines(conditionalLines)
+    metrics.ConditionalCodeShare = util.Ratio(conditional, code)
+    return metrics, code, conditional, tangling
+}
+
+// Parse the name and the argument tokens of a directive token.
+func parseDirective(token Token) directive{
+    text := strings.TrimLeft(strings.TrimPrefix(token.Text, "#"), " \t")
+    end := 0
+    for end < len(text) && isIdentPart(text[end]){
+        end += 1
+    }
+
+    var args []Token
+    for _, arg := range(Tokenize(text[end:])){
+        if arg.Kind != Comment{
+            args = append(args, arg)
+        }
+    }
+
+    return directive{name: text[:end], args: args}
+}
+
+// Test if the first conditional block of the directives
+// is an include guard covering all other directives.
+func isIncludeGuard(directives []directive) bool{
+    if len(directives) < 3 || directives[len(directives) - 1].name != "endif"{
+        return false
+    }
+
+    // find the guard macro
+    first := directives[0]
+    macro := ""
+    switch{
+    case first.name == "ifndef" && len(first.args) == 1:
+        macro = first.args[0].Text
+    case first.name == "if" && len(first.args) >= 3 && first.args[0].Text == "!" && first.args[1].Text == "defined":
+        macro = strings.Trim(first.args[len(first.args) - 1].Text, "()")
+        if macro == ""{
+            macro = first.args[len(first.args) - 2].Text
+        }
+    default:
+        return false
+    }
+
+    second := directives[1]
+    if second.name != "define" || len(second.args) == 0 || second.args[0].Text != macro{
+        return false
+    }
+
+    // the guard has to be closed by the last directive
+    depth := 0
+    for i, d := range(directives){
+        switch d.name{
+        case "if", "ifdef", "ifndef":
+            depth += 1
+        case "endif":
+            depth -= 1
+            if depth == 0{
+                return i == len(directives) - 1
+            }
+        }
+    }
+
+    return false
+}
+
+// Return the distinct configuration macros referenced
+// by the condition of a conditional directive.
+func conditionMacros(d directive) []string{
+    var macros []string
+    seen := make(map[string]bool)
+    for i := 0; i < len(d.args); i++{
+        arg := d.args[i]
+        if arg.Kind != Identifier || util.ContainsString(conditionKeywords, arg.Text){
+            continue
+        }
+
+        // skip the arguments of preprocessor functions
+        if util.ContainsString(conditionFunctions, arg.Text){
+            depth := 0
+            for i + 1 < len(d.args){
+                i += 1
+                if d.args[i].Text == "("{
+                    depth += 1
+                } else if d.args[i].Text == ")"{
+                    depth -= 1
+                    if depth <= 0{
+                        break
+                    }
+                }
+            }
+            continue
+        }
+
+        if !seen[arg.Text]{
+            seen[arg.Text] = true
+            macros = append(macros, arg.Text)
+        }
+    }
+
+    return macros
+}
+
+// Mark line l in lines, growing lines if needed.
+func setLine(lines []bool, l int) []bool{
+    for len(lines) <= l{
+        lines = append(lines, false)
+    }
+    lines[l] = true
+
+    return lines
+}
+
+// Count the marked lines.
+func countLines(lines []bool) int{
+    count := 0
+    for _, marked := range(lines){
+        if marked{
+            count += 1
+        }
+    }
+
+    return count
+}
@@ -0,0 +1,133 @@
+package stc
+
+import (
+    "sort"
+    "time"
+
+    "github.com/j-bhm/CppGitMining/pkg/git"
+    "github.com/j-bhm/CppGitMining/pkg/sct"
+    "github.com/j-bhm/CppGitMining/pkg/util"
+
+    gogit "github.com/go-git/go-git/v5"
+)
+
+// struct describing a developer pair whose coordination
+// is required by the code but not observed in the history
+type UncoordinatedPair struct{
+    A string
+    B string
+    Requirement float64 // summed weight of the dependencies between their files
+}
+
+// Run the socio-technical congruence analysis on the repository
+// in path. Requires the output of a previous sct run.
+// Outputs a map with the following fields:
+//   CoordinationRequirementCount int
+//   CoordinationCount            int
+//   Congruence                   float64
+//   UncoordinatedPairs           []UncoordinatedPair
+func RunStcAnalysis(path string, opts util.Options) (map[string]interface{}, error){
+    // load sct output
+    util.PrintDebug("loading sct output", opts)
+    sctJson, err := sct.LoadSctOutput(path, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    sctJson, err = sct.FilterSctJson(sctJson, path, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // load history
+    repo, err := gogit.PlainOpen(path)
+
+    if err != nil{
+        return nil, err
+    }
+
+    history, err := git.LoadHistory(repo, opts)
+
+    if err != nil{
+        return nil, err
+    }
+
+    // analyse congruence
+    util.PrintDebug("analysing socio-technical congruence", opts)
+    return AnalyseCongruence(sctJson, sct.SctNodePaths(sctJson, path), history, opts), nil
+}
+
+// Compare the coordination required by the file dependencies
+// of sctJson with the developer collaborations in history.
+// paths maps the sct node ids to repository paths.
+// Two developers are required to coordinate if one of them
+// modified a file depending on a file modified by the other.
+// Returns a map with the following fields:
+//   CoordinationRequirementCount int
+//   CoordinationCount            int
+//   Congruence                   float64
+//   UncoordinatedPairs           []UncoordinatedPair
+// UncoordinatedPairs lists the opts.TopN required but
+// uncoordinated pairs with the highest requirement.
+func AnalyseCongruence(sctJson *sct.SctJson, paths map[string]string, history []*git.HistoryCommit, opts util.Options) map[string]interface{}{
+    // lift file dependencies to developer pairs
+    authors := git.FileAuthors(history, opts)
+    required := make(map[git.DevPair]float64)
+    for _, edge := range(sctJson.Edges){
+        startAuthors := authors[paths[edge.Start]]
+        endAuthors := authors[paths[edge.End]]
+        for a := range(startAuthors){
+            for b := range(endAuthors){
+                if a != b{
+                    required[git.NewDevPair(a, b)] += edge.Weight
+                }
+            }
+        }
+    }
+
+    // compare with actual collaborations
+    window := time.Duration(opts.DevNetworkWindow) * 24 * time.Hour
+    _, actual := git.Collaborations(history, window, opts)
+    coordinated := 0
+    var uncoordinated []UncoordinatedPair
+    for pair, requirement := range(required){
+        if actual[pair] > 0{
+            coordinated += 1
+        } else{
+            uncoordinated = append(uncoordinated, UncoordinatedPair{pair.A, pair.B, requirement})
+        }
+    }
+
+    // rank uncoordinated pairs
+    sort.Slice(uncoordinated, func(i, j int) bool{
+        if uncoordinated[i].Requirement != uncoordinated[j].Requirement{
+            return uncoordinated[i].Requirement > uncoordinated[j].Requirement
+        }
+
+        if uncoordinated[i].A != uncoordinated[j].A{
+            return uncoordinated[i].A < uncoordinated[j].A
+        }
+
+        return uncoordinated[i].B < uncoordinated[j].B
+    })
+
+    if opts.TopN > 0 && len(uncoordinated) > opts.TopN{
+        uncoordinated = uncoordinated[:opts.TopN]
+    }
+
+    congruence := 0.0
+    if len(required) > 0{
+        congruence = float64(coordinated) / float64(len(required))
+    }
+
+    // set result values
+    result := make(map[string]interface{})
+    result["CoordinationRequirementCount"] = len(required)
+    result["CoordinationCount"] = coordinated
+    result["Congruence"] = congruence
+    result["UncoordinatedPairs"] = uncoordinated
+
+    return result
+}
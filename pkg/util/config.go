@@ -0,0 +1,53 @@
+package util
+
+import (
+    "encoding/json"
+    "os"
+)
+
+// struct holding the options that can be
+// set for a single repository
+type RepoConfig struct{
+    Language string  // name of the language profile
+    Include []string // glob patterns added to the included paths
+    Exclude []string // glob patterns added to the excluded paths
+}
+
+// Parse the repository configurations in the json file at path.
+// The file maps repository names, i.e. the base names of the
+// repository urls without ".git", to their configuration.
+func LoadRepoConfigs(path string) (map[string]RepoConfig, error){
+    data, err := os.ReadFile(path)
+    
+    if err != nil{
+        return nil, err
+    }
+    
+    configs := make(map[string]RepoConfig)
+    err = json.Unmarshal(data, &configs)
+    
+    if err != nil{
+        return nil, err
+    }
+    
+    return configs, nil
+}
+
+// Return opts with the options of the repository
+// name in configs replacing the global ones.
+// Path filters are added to the global ones.
+func RepoOptions(opts Options, configs map[string]RepoConfig, name string) Options{
+    config, ok := configs[name]
+    if !ok{
+        return opts
+    }
+    
+    if config.Language != ""{
+        opts.Language = config.Language
+    }
+    
+    opts.Include = append(append([]string{}, opts.Include...), config.Include...)
+    opts.Exclude = append(append([]string{}, opts.Exclude...), config.Exclude...)
+    
+    return opts
+}
@@ -6,6 +6,8 @@ import (
     "fmt"
     "os"
     "os/exec"
+    "sort"
+    "time"
 )
 
 // directory for all output/temp files and directories
@@ -19,6 +21,55 @@ type Options struct{
     ForceGit bool  // reload gits ignoring old saves
     ForceSct bool  // rerun sct analysis ignoring old outputs
     ForceGct bool  // rerun gct analysis ignoring old outputs
+    Jobs int       // number of parallel workers
+    TopN int       // length of ranked result lists, 0 for no limit
+    Language string   // name of the language profile
+    Include []string  // glob patterns of analysed paths, empty for all
+    Exclude []string  // glob patterns of paths left out of the analyses
+    GeneratedMinLines int  // lines added in a single commit above which files count as generated, 0 to disable
+    Rev string        // revision analysed instead of HEAD
+    Refs []string     // ref sets from which the history is traversed
+    FirstParent bool  // only follow the first parent of merge commits
+    RangeBase string  // commits reachable from this revision are excluded
+    Since time.Time   // commits authored before are excluded
+    Until time.Time   // commits authored after are excluded
+    
+    Blame bool          // run the blame analysis
+    BlameMaxSize int64  // maximum size in bytes of blamed files, 0 for no limit
+    BlameAgeYears int   // age in years above which lines count as old
+    Branches bool       // run the branch topology analysis
+    Classify bool            // classify commits by message and diff shape
+    ClassifyKeywords string  // file with keyword rules for the commit classification
+    Szz bool                 // identify bug-inducing commits with szz
+    Trailers bool            // analyse commit trailers
+    DevNetwork bool          // analyse the developer collaboration network
+    DevNetworkWindow int     // time window in days for developer collaborations
+    Turnover bool            // analyse contributor turnover and retention
+    InactivityDays int       // days without commit after which contributors leave
+    CoreThreshold float64    // share of commits made by core contributors
+    Entropy bool             // calculate the change entropy
+    EntropyPeriodDays int    // length of the change entropy periods in days
+    EntropyBurstHours int    // gap in hours separating change bursts, 0 to use periods
+    Duplicates bool          // detect duplicate patches, cherry-picks and reverts
+    HotspotChurn bool            // rank hotspots by churn instead of change frequency
+    HotspotNorm string           // normalisation of the hotspot components
+    HotspotChangeWeight float64  // weight of the change frequency or churn
+    HotspotSctWeight float64     // weight of the static coupling degree
+    HotspotGctWeight float64     // weight of the git coupling degree
+    HotspotComplexityWeight float64  // weight of the cyclomatic complexity, 0 to leave it out
+    PreprocessorCoChange bool        // compare the change coupling of ifdef-heavy files
+    IfdefHeavyShare float64          // share of conditional code of ifdef-heavy files
+    ReleaseMetrics []string      // metrics computed at every release tag
+    EvolutionSampling string     // sampling of the revisions analysed over time
+    EvolutionStep int            // number of tags between sampled revisions
+    CouplingWindowDays int       // length of the change coupling windows in days
+    CouplingWindowCommits int    // length of the change coupling windows in commits, 0 to use days
+    CouplingWindowStep int       // days or commits between window starts, 0 for the window length
+    CouplingMinStrength int      // co-changes of strongly coupled file pairs
+    IgnoreRevsFile string          // file listing commits ignored in churn and coupling
+    UseBlameIgnoreRevs bool        // ignore the commits in .git-blame-ignore-revs
+    MassChangeFiles int            // changed files above which commits are ignored, 0 to disable
+    MassChangePercentile float64   // percentile of changed files above which commits are ignored, 0 to disable
 }
 
 // Print an error message.
@@ -97,3 +148,55 @@ func ParseInput(path string) (urls []string, commands []string, err error) {
     
     return urls, commands, nil
 }
+
+// Test if values contains s.
+func ContainsString(values []string, s string) bool {
+    for _, v := range(values){
+        if v == s{
+            return true
+        }
+    }
+    
+    return false
+}
+
+// Divide a by b, or return 0 if b is 0.
+func Ratio(a, b int) float64{
+    if b == 0{
+        return 0
+    }
+    
+    return float64(a) / float64(b)
+}
+
+// Calculate the mean of values, or 0 for no values.
+func Mean(values []float64) float64{
+    if len(values) == 0{
+        return 0
+    }
+    
+    sum := 0.0
+    for _, v := range(values){
+        sum += v
+    }
+    
+    return sum / float64(len(values))
+}
+
+// Calculate the median of values, or 0 for no values.
+// The values are left unchanged.
+func Median(values []float64) float64{
+    if len(values) == 0{
+        return 0
+    }
+    
+    sorted := make([]float64, len(values))
+    copy(sorted, values)
+    sort.Float64s(sorted)
+    middle := len(sorted) / 2
+    if len(sorted) % 2 == 0{
+        return (sorted[middle - 1] + sorted[middle]) / 2
+    }
+    
+    return sorted[middle]
+}